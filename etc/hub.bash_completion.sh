@@ -12,11 +12,17 @@ if declare -F _git > /dev/null; then
     cat <<-EOF
 alias
 pull-request
+pr
+issue
+label
+release
 fork
 create
 browse
 compare
 ci-status
+sync
+api
 EOF
     __git_list_all_commands_without_hub
   }
@@ -51,9 +57,9 @@ EOF
     __gitcomp "$s $shells"
   }
 
-  # hub browse [-u] [--|[USER/]REPOSITORY] [SUBPAGE]
+  # hub browse [-u] [-c] [--upstream] [--|[USER/]REPOSITORY] [SUBPAGE]
   _git_browse() {
-    local i c=2 u=-u repo subpage
+    local i c=2 u=-u flags="-c --upstream --issues --pulls --wiki --releases" repo subpage
     local -A subpages
     subpages["/"]="commits issues tree wiki pulls branches stargazers
       contributors network network/ graphs graphs/"
@@ -65,6 +71,8 @@ EOF
         -u)
           unset u
           ;;
+        -*)
+          ;;
         *)
           if [ -z "$repo" ]; then
             repo=$i
@@ -76,7 +84,7 @@ EOF
       ((c++))
     done
     if [ -z "$repo" ]; then
-      __gitcomp "$u -- $(__hub_github_repos '\p')"
+      __gitcomp "$u $flags -- $(__hub_github_repos '\p')"
     elif [ -z "$subpage" ]; then
       case "$cur" in
         */*)
@@ -92,7 +100,7 @@ EOF
     fi
   }
 
-  # hub compare [-u] [USER[/REPOSITORY]] [[START...]END]
+  # hub compare [-u] [-c] [--stat] [USER[/REPOSITORY]] [[START...]END]
   _git_compare() {
     local i c=$((cword - 1)) u=-u user remote owner repo arg_repo rev
     while [ $c -gt 1 ]; do
@@ -101,6 +109,8 @@ EOF
         -u)
           unset u
           ;;
+        -*)
+          ;;
         *)
           if [ -z "$rev" ]; then
             # Even though the logic below is able to complete both user/repo
@@ -176,16 +186,16 @@ EOF
     esac
   }
 
-  # hub create [NAME] [-p] [-d DESCRIPTION] [-h HOMEPAGE]
+  # hub create [NAME] [-p] [-d DESCRIPTION] [-h HOMEPAGE] [--remote-name REMOTE] [-o] [-c]
   _git_create() {
-    local i c=2 name repo flags="-p -d -h"
+    local i c=2 name repo flags="-p -d -h --remote-name -o -c"
     while [ $c -lt $cword ]; do
       i="${words[c]}"
       case "$i" in
-        -d|-h)
+        -d|-h|--remote-name)
           ((c++))
           ;;&
-        -p|-d|-h)
+        -p|-d|-h|--remote-name|-o|-c)
           flags=${flags/$i/}
           ;;
         *)
@@ -198,7 +208,7 @@ EOF
       repo=$(basename "$(pwd)")
     fi
     case "$prev" in
-      -d|-h)
+      -d|-h|--remote-name)
         COMPREPLY=()
         ;;
       -p|*)
@@ -224,16 +234,16 @@ EOF
     fi
   }
 
-  # hub pull-request [-f] [-m <MESSAGE>|-F <FILE>|-i <ISSUE>|<ISSUE-URL>] [-b <BASE>] [-h <HEAD>]
+  # hub pull-request [-f] [-p] [-o] [-c] [-m <MESSAGE>|-F <FILE>|-i <ISSUE>|<ISSUE-URL>] [-b <BASE>] [-h <HEAD>]
   _git_pull_request() {
-    local i c=2 flags="-f -m -F -i -b -h"
+    local i c=2 flags="-f -p -o -c -m -F -i -b -h --base --head"
     while [ $c -lt $cword ]; do
       i="${words[c]}"
       case "$i" in
-        -m|-F|-i|-b|-h)
+        -m|-F|-i|-b|-h|--base|--head)
           ((c++))
           ;;&
-        -f|-m|-F|-i|-b|-h)
+        -f|-p|-o|-c|-F|-i|-b|-h|--base|--head)
           flags=${flags/$i/}
           ;;
       esac
@@ -243,19 +253,39 @@ EOF
       -i)
         COMPREPLY=()
         ;;
-      -b|-h)
+      -b|-h|--base|--head)
         # (Doesn't seem to need this...)
         # Uncomment the following line when 'owner/repo:[TAB]' misbehaved
         #_get_comp_words_by_ref -n : cur
         __gitcomp_nl "$(__hub_heads)"
         __ltrim_colon_completions "$cur"
         ;;
-      -f|*)
+      -f|-p|-o|-c|*)
         __gitcomp "$flags"
         ;;
     esac
   }
 
+  # hub api [-X METHOD] [-f|-F KEY=VALUE] [-H HEADER] [--paginate] [-t] PATH
+  _git_api() {
+    case "$prev" in
+      -X)
+        __gitcomp "GET POST PATCH PUT DELETE HEAD"
+        ;;
+      -f|-F|-H)
+        COMPREPLY=()
+        ;;
+      *)
+        __gitcomp "-X -f -F -H --paginate -t"
+        ;;
+    esac
+  }
+
+  # hub sync [--dry-run]
+  _git_sync() {
+    __gitcomp "--dry-run"
+  }
+
   ###################
   # Helper functions
   ###################
@@ -0,0 +1,194 @@
+Feature: hub api
+  Background:
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: GET resource
+    Given the GitHub API server:
+      """
+      get('/user') {
+        halt 401 unless request.env['HTTP_AUTHORIZATION'] == 'token OTOKEN'
+        json :login => "mislav"
+      }
+      """
+    When I successfully run `hub api user`
+    Then the output should contain exactly:
+      """
+      {"login":"mislav"}\n
+      """
+
+  Scenario: Typed and raw fields make a POST request
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/dotfiles/issues') {
+        assert :title => "true", :draft => false, :milestone => 12, :assignee => nil
+        status 201
+        json :number => 4
+      }
+      """
+    When I successfully run `hub api repos/mislav/dotfiles/issues -f title=true -F draft=false -F milestone=12 -F assignee=null`
+    Then the output should contain exactly:
+      """
+      {"number":4}\n
+      """
+
+  Scenario: Fields of a GET request go in the query string
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles/issues') {
+        assert :state => "closed", :labels => "bug"
+        json []
+      }
+      """
+    When I successfully run `hub api -X get repos/mislav/dotfiles/issues -f state=closed -f labels=bug`
+    Then the output should contain exactly "[]\n"
+
+  Scenario: Field from a file
+    Given a file named "body.md" with:
+      """
+      Some details
+      """
+    Given the GitHub API server:
+      """
+      patch('/repos/mislav/dotfiles/issues/4') {
+        assert :body => "Some details\n"
+        json :number => 4
+      }
+      """
+    When I successfully run `hub api -X PATCH repos/mislav/dotfiles/issues/4 -F body=@body.md`
+    Then the output should contain exactly:
+      """
+      {"number":4}\n
+      """
+
+  Scenario: Custom headers
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles/topics') {
+        halt 415 unless request.env['HTTP_ACCEPT'] == 'application/vnd.github.mercy-preview+json'
+        json :names => ["vim"]
+      }
+      """
+    When I successfully run `hub api -H "Accept: application/vnd.github.mercy-preview+json" repos/mislav/dotfiles/topics`
+    Then the output should contain exactly:
+      """
+      {"names":["vim"]}\n
+      """
+
+  Scenario: Paginate and flatten
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles/labels') {
+        if params[:page] == "2"
+          json [{ :name => "docs", :default => false }]
+        else
+          response.headers['Link'] = '<https://api.github.com/repos/mislav/dotfiles/labels?page=2>; rel="next"'
+          json [{ :name => "bug", :default => true, :description => nil }]
+        end
+      }
+      """
+    When I successfully run `hub api --paginate -t repos/mislav/dotfiles/labels`
+    Then the output should contain exactly:
+      """
+      .[0].default	true
+      .[0].description	null
+      .[0].name	bug
+      .[1].default	false
+      .[1].name	docs\n
+      """
+
+  Scenario: Paginated lists are concatenated
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles/labels') {
+        if params[:page] == "2"
+          json [{ :name => "docs" }]
+        else
+          response.headers['Link'] = '<https://api.github.com/repos/mislav/dotfiles/labels?page=2>; rel="next"'
+          json [{ :name => "bug" }]
+        end
+      }
+      """
+    When I successfully run `hub api --paginate repos/mislav/dotfiles/labels`
+    Then the output should contain exactly:
+      """
+      [{"name": "bug"}, {"name": "docs"}]\n
+      """
+
+  Scenario: Error response
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/nonexistent') {
+        status 404
+        json :message => "Not Found"
+      }
+      """
+    When I run `hub api repos/mislav/nonexistent`
+    Then the exit status should be 1
+    And the output should contain exactly:
+      """
+      {"message":"Not Found"}\n
+      """
+
+  Scenario: GraphQL query
+    Given a file named "query.graphql" with:
+      """
+      query($first: Int!) { viewer { repositories(first: $first) { totalCount } } }
+      """
+    Given the GitHub API server:
+      """
+      post('/graphql') {
+        halt 422 unless params[:query].include?('viewer')
+        halt 422 unless params[:variables] == { 'first' => 10 }
+        json :data => { :viewer => { :repositories => { :totalCount => 42 } } }
+      }
+      """
+    When I successfully run `hub api graphql -f query=@query.graphql -F first=10 -t`
+    Then the output should contain exactly:
+      """
+      .data.viewer.repositories.totalCount	42\n
+      """
+
+  Scenario: GraphQL errors
+    Given the GitHub API server:
+      """
+      post('/graphql') {
+        json :errors => [{ :message => "Field 'nope' doesn't exist on type 'Query'" }]
+      }
+      """
+    When I run `hub api graphql -f query={nope}`
+    Then the exit status should be 1
+    And the output should contain "Field 'nope' doesn't exist"
+
+  Scenario: Enterprise host
+    Given the GitHub API server:
+      """
+      before { halt 401 unless request.env['HTTP_AUTHORIZATION'] == 'token FITOKEN' }
+      get('/api/v3/user', :host_name => 'git.my.org') {
+        json :login => "mislav"
+      }
+      post('/api/graphql', :host_name => 'git.my.org') {
+        json :data => { :viewer => { :login => "mislav" } }
+      }
+      """
+    And the "origin" remote has url "git@git.my.org:mislav/dotfiles.git"
+    And I am "mislav" on git.my.org with OAuth token "FITOKEN"
+    And "git.my.org" is a whitelisted Enterprise host
+    When I successfully run `hub api user`
+    Then the output should contain exactly:
+      """
+      {"login":"mislav"}\n
+      """
+    When I successfully run `hub api graphql -f query={viewer{login}}`
+    Then the output should contain:
+      """
+      {"data":{"viewer":{"login":"mislav"}}}
+      """
+
+  Scenario: Invalid field
+    When I run `hub api user -f title`
+    Then the exit status should be 1
+    And the stderr should contain exactly:
+      """
+      Error: invalid field "title" (use <KEY>=<VALUE>)\n
+      """
@@ -106,3 +106,68 @@ Feature: hub browse
       Warning: the `-p` flag has no effect anymore\n
       """
     But "open https://github.com/defunkt/hub" should be run
+
+  Scenario: Subpage shorthand flag
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    When I successfully run `hub browse --pulls`
+    Then "open https://github.com/mislav/dotfiles/pulls" should be run
+
+  Scenario: File at a line on the current branch
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And I am on the "feature" branch with upstream "origin/experimental"
+    And a file named "lib/my app.rb" with:
+      """
+      puts "hello"
+      """
+    When I successfully run `hub browse "lib/my app.rb:30"`
+    Then "open https://github.com/mislav/dotfiles/blob/experimental/lib/my%20app.rb#L30" should be run
+
+  Scenario: Directory in the current project
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And a directory named "lib"
+    When I successfully run `hub browse lib`
+    Then "open https://github.com/mislav/dotfiles/tree/master/lib" should be run
+
+  Scenario: Copy the URL instead of browsing
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    When I successfully run `hub browse -c -- issues`
+    Then there should be no output
+    And the clipboard should contain "https://github.com/mislav/dotfiles/issues"
+    But "open https://github.com/mislav/dotfiles/issues" should not be run
+
+  Scenario: Copy and output the URL
+    When I successfully run `hub browse --copy --url mislav/dotfiles`
+    Then the output should contain exactly "https://github.com/mislav/dotfiles\n"
+    And the clipboard should contain "https://github.com/mislav/dotfiles"
+
+  Scenario: Upstream remote
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And the "upstream" remote has url "git://github.com/jeremy/dotfiles.git"
+    When I successfully run `hub browse --upstream --issues`
+    Then "open https://github.com/jeremy/dotfiles/issues" should be run
+
+  Scenario: Parent of a fork
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles') {
+        json :full_name => "mislav/dotfiles",
+             :parent => { :full_name => "jeremy/dotfiles" }
+      }
+      """
+    When I successfully run `hub browse --upstream`
+    Then "open https://github.com/jeremy/dotfiles" should be run
+
+  Scenario: Upstream of a repo that is not a fork
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles') {
+        json :full_name => "mislav/dotfiles"
+      }
+      """
+    When I run `hub browse --upstream`
+    Then the stderr should contain exactly "Aborted: mislav/dotfiles is not a fork\n"
+    And the exit status should be 1
@@ -51,3 +51,78 @@ Feature: hub ci-status
     When I run `hub ci-status`
     Then the stderr should contain "Aborted: the origin remote doesn't point to a GitHub repository.\n"
     And the exit status should be 1
+
+  Scenario: Verbose output of statuses and check runs
+    Given there is a commit named "the_sha"
+    Given the GitHub API server:
+      """
+      get('/repos/michiels/pencilbox/commits/:sha/status') {
+        json :state => "success", :statuses => [
+          { :context => "ci/travis", :state => "success",
+            :target_url => "https://travis-ci.org/michiels/pencilbox/builds/1" }
+        ]
+      }
+      get('/repos/michiels/pencilbox/commits/:sha/check-runs') {
+        json :total_count => 2, :check_runs => [
+          { :name => "lint", :status => "in_progress", :conclusion => nil,
+            :started_at => "2013-05-01T12:00:00Z", :completed_at => nil,
+            :details_url => "https://github.com/michiels/pencilbox/runs/2" },
+          { :name => "build", :status => "completed", :conclusion => "success",
+            :started_at => "2013-05-01T12:00:00Z", :completed_at => "2013-05-01T12:01:23Z",
+            :details_url => "https://github.com/michiels/pencilbox/runs/1" },
+        ]
+      }
+      """
+    When I run `hub ci-status -v the_sha`
+    Then the output should contain exactly:
+      """
+      ✔  build      (1m23s)  https://github.com/michiels/pencilbox/runs/1
+      ✔  ci/travis           https://travis-ci.org/michiels/pencilbox/builds/1
+      ●  lint                https://github.com/michiels/pencilbox/runs/2
+      pending\n
+      """
+    And the exit status should be 2
+
+  Scenario: Custom format and pending checks allowed
+    Given there is a commit named "the_sha"
+    Given the GitHub API server:
+      """
+      get('/repos/michiels/pencilbox/commits/:sha/status') {
+        json :state => "pending", :statuses => []
+      }
+      get('/repos/michiels/pencilbox/commits/:sha/check-runs') {
+        json :total_count => 2, :check_runs => [
+          { :name => "test", :status => "completed", :conclusion => "skipped" },
+          { :name => "build", :status => "queued", :conclusion => nil },
+        ]
+      }
+      """
+    When I run `hub ci-status the_sha --pending-ok --format "%t: %S%n"`
+    Then the output should contain exactly:
+      """
+      build: pending
+      test: success\n
+      """
+    And the exit status should be 0
+
+  Scenario: Failing check run
+    Given there is a commit named "the_sha"
+    Given the GitHub API server:
+      """
+      get('/repos/michiels/pencilbox/commits/:sha/status') {
+        json :state => "pending", :statuses => []
+      }
+      get('/repos/michiels/pencilbox/commits/:sha/check-runs') {
+        json :total_count => 1, :check_runs => [
+          { :name => "test", :status => "completed", :conclusion => "timed_out",
+            :html_url => "https://github.com/michiels/pencilbox/runs/3" },
+        ]
+      }
+      """
+    When I run `hub ci-status --verbose --pending-ok the_sha`
+    Then the output should contain exactly:
+      """
+      ✖  test  https://github.com/michiels/pencilbox/runs/3
+      failure\n
+      """
+    And the exit status should be 1
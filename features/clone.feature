@@ -1,17 +1,17 @@
 Feature: hub clone
   Scenario: Clone a public repo
     When I successfully run `hub clone rtomayko/ronn`
-    Then it should clone "git://github.com/rtomayko/ronn.git"
+    Then it should clone "https://github.com/rtomayko/ronn.git"
     And there should be no output
 
   Scenario: Clone a public repo with period in name
     When I successfully run `hub clone hookio/hook.js`
-    Then it should clone "git://github.com/hookio/hook.js.git"
+    Then it should clone "https://github.com/hookio/hook.js.git"
     And there should be no output
 
   Scenario: Clone a public repo that starts with a period
     When I successfully run `hub clone zhuangya/.vim`
-    Then it should clone "git://github.com/zhuangya/.vim.git"
+    Then it should clone "https://github.com/zhuangya/.vim.git"
     And there should be no output
 
   Scenario: Clone a public repo with HTTPS
@@ -20,10 +20,50 @@ Feature: hub clone
     Then it should clone "https://github.com/rtomayko/ronn.git"
     And there should be no output
 
+  Scenario: Clone a public repo with the git protocol
+    When I successfully run `git config --global hub.protocol git`
+    And I successfully run `hub clone rtomayko/ronn`
+    Then it should clone "git://github.com/rtomayko/ronn.git"
+    And there should be no output
+
+  Scenario: Clone a repo I'm a collaborator on
+    Given I am "mislav" on github.com with OAuth token "OTOKEN"
+    Given the GitHub API server:
+      """
+      get('/repos/rtomayko/ronn') {
+        json :full_name => "rtomayko/ronn", :permissions => { :push => true }
+      }
+      """
+    When I successfully run `hub clone rtomayko/ronn`
+    Then it should clone "git@github.com:rtomayko/ronn.git"
+    And there should be no output
+
+  Scenario: Clone a repo I can't push to
+    Given I am "mislav" on github.com with OAuth token "OTOKEN"
+    Given the GitHub API server:
+      """
+      get('/repos/rtomayko/ronn') {
+        json :full_name => "rtomayko/ronn", :permissions => { :push => false }
+      }
+      """
+    When I successfully run `hub clone rtomayko/ronn`
+    Then it should clone "https://github.com/rtomayko/ronn.git"
+    And there should be no output
+
+  Scenario: Clone a wiki
+    When I successfully run `hub clone rtomayko/ronn.wiki`
+    Then it should clone "https://github.com/rtomayko/ronn.wiki.git"
+    And there should be no output
+
+  Scenario: Clone with flags that take values
+    When I successfully run `hub clone --depth 1 --recurse-submodules -c core.autocrlf=false rtomayko/ronn docs`
+    Then "git clone --depth 1 --recurse-submodules -c core.autocrlf=false https://github.com/rtomayko/ronn.git docs" should be run
+    And there should be no output
+
   Scenario: Clone command aliased
     When I successfully run `git config --global alias.c "clone --bare"`
     And I successfully run `hub c rtomayko/ronn`
-    Then "git clone --bare git://github.com/rtomayko/ronn.git" should be run
+    Then "git clone --bare https://github.com/rtomayko/ronn.git" should be run
     And there should be no output
 
   Scenario: Unchanged public clone
@@ -47,17 +47,22 @@ Feature: hub browse
   Scenario: Compare 2-dots range for tags
     When I successfully run `hub compare 1.0..fix`
     Then there should be no output
-    And "open https://github.com/mislav/dotfiles/compare/1.0...fix" should be run
+    And "open https://github.com/mislav/dotfiles/compare/1.0..fix" should be run
 
   Scenario: Compare 2-dots range for SHAs
     When I successfully run `hub compare 1234abc..3456cde`
     Then there should be no output
-    And "open https://github.com/mislav/dotfiles/compare/1234abc...3456cde" should be run
+    And "open https://github.com/mislav/dotfiles/compare/1234abc..3456cde" should be run
 
   Scenario: Compare 2-dots range with "user:repo" notation
     When I successfully run `hub compare henrahmagix:master..2b10927`
     Then there should be no output
-    And "open https://github.com/mislav/dotfiles/compare/henrahmagix:master...2b10927" should be run
+    And "open https://github.com/mislav/dotfiles/compare/henrahmagix:master..2b10927" should be run
+
+  Scenario: Compare across forks
+    When I successfully run `hub compare mislav:master...defunkt:feature`
+    Then there should be no output
+    And "open https://github.com/mislav/dotfiles/compare/mislav:master...defunkt:feature" should be run
 
   Scenario: Complex range is unchanged
     When I successfully run `hub compare @{a..b}..@{c..d}`
@@ -68,9 +73,40 @@ Feature: hub browse
     Given the "origin" remote has url "git://github.com/mislav/dotfiles.wiki.git"
     When I successfully run `hub compare 1.0..fix`
     Then there should be no output
-    And "open https://github.com/mislav/dotfiles/wiki/_compare/1.0...fix" should be run
+    And "open https://github.com/mislav/dotfiles/wiki/_compare/1.0..fix" should be run
 
   Scenario: Compare fork
     When I successfully run `hub compare anotheruser feature`
     Then there should be no output
     And "open https://github.com/anotheruser/dotfiles/compare/feature" should be run
+
+  Scenario: Copy the URL
+    When I successfully run `hub compare -c 1.0...fix`
+    Then there should be no output
+    And the clipboard should contain "https://github.com/mislav/dotfiles/compare/1.0...fix"
+    But "open https://github.com/mislav/dotfiles/compare/1.0...fix" should not be run
+
+  Scenario: Print commits of a branch compared to the default branch
+    Given I am "mislav" on github.com with OAuth token "OTOKEN"
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles') {
+        json :full_name => "mislav/dotfiles", :default_branch => "develop"
+      }
+      get('/repos/mislav/dotfiles/compare/develop...feature') {
+        json :ahead_by => 2, :behind_by => 1, :total_commits => 2,
+             :commits => [
+               { :sha => "1234567890abc", :commit => { :message => "Add feature\n\nDetails" } },
+               { :sha => "abcdef1234567", :commit => { :message => "Fix typo" } },
+             ]
+      }
+      """
+    When I successfully run `hub compare --stat feature`
+    Then the output should contain exactly:
+      """
+      develop...feature: 2 ahead, 1 behind
+
+      1234567 Add feature
+      abcdef1 Fix typo\n
+      """
+    And "open https://github.com/mislav/dotfiles/compare/feature" should not be run
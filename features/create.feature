@@ -106,6 +106,41 @@ Feature: hub create
     When I successfully run `hub create`
     Then the output should contain "mislav/dotfiles already exists on github.com\n"
     And the url for "origin" should be "git@github.com:mislav/dotfiles.git"
+    And the output should contain "existing repository detected: mislav/dotfiles\n"
+
+  Scenario: Origin remote points to another repo
+    Given the "origin" remote has url "git://github.com/mislav/other.git"
+    When I run `hub create`
+    Then the stderr should contain exactly:
+      """
+      Aborted: remote "origin" already exists and points to git://github.com/mislav/other.git
+      (use `--remote-name` to add the new repository under another name)\n
+      """
+    And the exit status should be 1
+
+  Scenario: Custom remote name
+    Given the GitHub API server:
+      """
+      post('/user/repos') {
+        json :full_name => 'mislav/dotfiles'
+      }
+      """
+    And the "origin" remote has url "git://github.com/mislav/other.git"
+    When I successfully run `hub create --remote-name github`
+    Then the url for "github" should be "git@github.com:mislav/dotfiles.git"
+    And the url for "origin" should be "git://github.com/mislav/other.git"
+
+  Scenario: Open and copy the new repo
+    Given the GitHub API server:
+      """
+      post('/orgs/acme/repos') {
+        json :full_name => 'acme/dotfiles'
+      }
+      """
+    When I successfully run `hub create -o -c acme/dotfiles`
+    Then the output should contain exactly "created repository: acme/dotfiles\n"
+    And "open https://github.com/acme/dotfiles" should be run
+    And the clipboard should contain "https://github.com/acme/dotfiles"
 
   Scenario: API response changes the clone URL
     Given the GitHub API server:
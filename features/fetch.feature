@@ -79,6 +79,15 @@ Feature: hub fetch
     But there should be no "mygrp" remote
     And there should be no "typo" remote
 
+  Scenario: Fetch multiple comma-separated with dashes
+    Given the GitHub API server:
+      """
+      get('/repos/:owner/dotfiles') { json :private => false }
+      """
+    When I successfully run `hub fetch mislav,ankit-maverick`
+    Then "git fetch --multiple mislav ankit-maverick" should be run
+    And the url for "ankit-maverick" should be "git://github.com/ankit-maverick/dotfiles.git"
+
   Scenario: Fetch multiple comma-separated
     Given the GitHub API server:
       """
@@ -97,3 +106,7 @@ Feature: hub fetch
     When I successfully run `hub fetch mislav`
     Then "git fetch mislav" should be run
     And there should be no "mislav" remote
+    And the stderr should contain exactly:
+      """
+      Warning: mislav/dotfiles doesn't exist on github.com; not adding a remote for mislav\n
+      """
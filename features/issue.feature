@@ -0,0 +1,261 @@
+Feature: hub issue
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: Show an issue
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/issues/42') {
+        json :number => 42, :title => "Broken links", :state => "open",
+          :user => { :login => "parkr" }, :created_at => "2013-05-01T12:00:00Z",
+          :labels => [{ :name => "bug" }, { :name => "docs" }],
+          :assignees => [{ :login => "mislav" }],
+          :body => "Some **links** are broken.",
+          :html_url => "https://github.com/mojombo/jekyll/issues/42"
+      }
+      get('/repos/mojombo/jekyll/issues/42/comments') {
+        json [{ :user => { :login => "mislav" }, :created_at => "2013-05-02T08:00:00Z",
+                :body => "On it." }]
+      }
+      """
+    When I successfully run `hub issue show #42 --comments`
+    Then the output should contain exactly:
+      """
+      Broken links #42
+      Open - parkr opened this on 2013-05-01
+      Labels: bug, docs
+      Assignees: mislav
+
+      Some links are broken.
+
+      mislav commented on 2013-05-02:
+      On it.
+
+      https://github.com/mojombo/jekyll/issues/42\n
+      """
+
+  Scenario: Close an issue with a comment
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/issues/42/comments') {
+        assert :body => "Fixed in 1.2"
+        status 201
+        json :html_url => "https://github.com/mojombo/jekyll/issues/42#issuecomment-1"
+      }
+      patch('/repos/mojombo/jekyll/issues/42') {
+        assert :state => "closed"
+        json :number => 42, :state => "closed"
+      }
+      """
+    When I successfully run `hub issue close https://github.com/mojombo/jekyll/issues/42 -m "Fixed in 1.2"`
+    Then the output should contain exactly "Closed issue #42\n"
+
+  Scenario: Reopen an issue
+    Given the GitHub API server:
+      """
+      patch('/repos/mojombo/jekyll/issues/42') {
+        assert :state => "open"
+        json :number => 42, :state => "open"
+      }
+      """
+    When I successfully run `hub issue reopen 42`
+    Then the output should contain exactly "Reopened issue #42\n"
+
+  Scenario: Comment from a file
+    Given a file named "comment.txt" with:
+      """
+      Can you add a test?
+      """
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/issues/42/comments') {
+        assert :body => "Can you add a test?"
+        status 201
+        json :html_url => "https://github.com/mojombo/jekyll/issues/42#issuecomment-2"
+      }
+      """
+    When I successfully run `hub issue comment 42 -F comment.txt`
+    Then the output should contain exactly "https://github.com/mojombo/jekyll/issues/42#issuecomment-2\n"
+
+  Scenario: Comment without a message
+    When I run `hub issue comment 42`
+    Then the stderr should contain exactly "Aborting due to empty comment (use `-m` or `-F`)\n"
+    And the exit status should be 1
+
+  Scenario: Issue not found
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/issues/42') {
+        status 404
+        json :message => "Not Found"
+      }
+      """
+    When I run `hub issue show 42`
+    Then the stderr should contain exactly "fatal: issue #42 not found in mojombo/jekyll\n"
+    And the exit status should be 1
+
+  Scenario: Repository access blocked
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/issues') {
+        status 451
+        json :message => "Repository access blocked",
+             :block => { :reason => "dmca", :html_url => "https://github.com/github/dmca/blob/master/notice.md" }
+      }
+      """
+    When I run `hub issue`
+    Then the stderr should contain exactly:
+      """
+      Error fetching issues: repository access blocked (HTTP 451)
+      Reason: dmca
+      See https://github.com/github/dmca/blob/master/notice.md\n
+      """
+    And the exit status should be 1
+
+  Scenario: List issues
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/issues') {
+        assert :state => nil
+        json [
+          { :number => 102, :title => "Broken links", :state => "open",
+            :labels => [{ :name => "bug" }, { :name => "docs" }] },
+          { :number => 101, :title => "Pull request", :state => "open", :labels => [],
+            :pull_request => { :url => "https://api.github.com/repos/mojombo/jekyll/pulls/101" } },
+          { :number => 9, :title => "Crash", :state => "open", :labels => [] }
+        ]
+      }
+      """
+    When I successfully run `hub issue`
+    Then the output should contain exactly:
+      """
+        #102  Broken links  bug, docs
+          #9  Crash\n
+      """
+
+  Scenario: Filters and format
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/issues') {
+        assert :state => "closed",
+               :labels => "bug,docs",
+               :assignee => "mislav",
+               :milestone => "3",
+               :creator => "parkr",
+               :mentioned => "mojombo",
+               :since => "2013-01-01T00:00:00Z",
+               :sort => "updated"
+        json [
+          { :number => 102, :title => "Broken links", :state => "closed",
+            :html_url => "https://github.com/mojombo/jekyll/issues/102",
+            :created_at => "2012-12-24T10:00:00Z",
+            :labels => [{ :name => "bug" }], :assignees => [{ :login => "mislav" }] },
+          { :number => 101, :title => "Pull request", :state => "closed",
+            :html_url => "https://github.com/mojombo/jekyll/pull/101",
+            :created_at => "2012-12-20T10:00:00Z", :labels => [], :assignees => [],
+            :pull_request => { :url => "https://api.github.com/repos/mojombo/jekyll/pulls/101" } }
+        ]
+      }
+      """
+    When I successfully run `hub issue list -s closed -l bug,docs -a mislav -M 3 -c parkr -@ mojombo --since 2013-01-01T00:00:00Z -o updated --include-pulls -f "%I %S %cI [%l] [%a]%n%U"`
+    Then the output should contain exactly:
+      """
+      102 closed 2012-12-24T10:00:00Z [bug] [mislav]
+      https://github.com/mojombo/jekyll/issues/102
+      101 closed 2012-12-20T10:00:00Z [] []
+      https://github.com/mojombo/jekyll/pull/101\n
+      """
+
+  Scenario: Limit
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/issues') {
+        json [
+          { :number => 102, :title => "Broken links", :labels => [] },
+          { :number => 9, :title => "Crash", :labels => [] }
+        ]
+      }
+      """
+    When I successfully run `hub issue -L 1 --format "%I"`
+    Then the output should contain exactly "102\n"
+
+  Scenario: Create an issue
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/milestones') {
+        assert :state => "all"
+        json [{ :number => 3, :title => "v1.1" }, { :number => 5, :title => "v1.2" }]
+      }
+      post('/repos/mojombo/jekyll/issues') {
+        assert :title => "Crash on startup",
+               :body => "Happens every time.",
+               :labels => ["bug", "ui"],
+               :assignees => ["mislav"],
+               :milestone => 5
+        status 201
+        json :html_url => "https://github.com/mojombo/jekyll/issues/43"
+      }
+      """
+    When I successfully run `hub issue create -m "Crash on startup" -m "Happens every time." -l bug,ui -a mislav -M V1.2`
+    Then the output should contain exactly "https://github.com/mojombo/jekyll/issues/43\n"
+
+  Scenario: Create an issue from a file and open it in a browser
+    Given a file named "issue-msg" with:
+      """
+      Crash on startup
+
+      Happens every time.
+      """
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/issues') {
+        assert :title => "Crash on startup",
+               :body => "Happens every time.",
+               :milestone => 3
+        status 201
+        json :html_url => "https://github.com/mojombo/jekyll/issues/43"
+      }
+      """
+    When I successfully run `hub issue create -F issue-msg -M 3 -o`
+    Then there should be no output
+    And "open https://github.com/mojombo/jekyll/issues/43" should be run
+
+  Scenario: Create an issue in the text editor with the issue template
+    Given the git commit editor is "vim"
+    And a file named ".github/ISSUE_TEMPLATE.md" with:
+      """
+      Steps to reproduce:
+      """
+    And the text editor adds:
+      """
+      Crash on startup
+      """
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/issues') {
+        assert :title => "Crash on startup",
+               :body => "Steps to reproduce:"
+        status 201
+        json :html_url => "https://github.com/mojombo/jekyll/issues/43"
+      }
+      """
+    When I successfully run `hub issue create`
+    Then the output should contain exactly "https://github.com/mojombo/jekyll/issues/43\n"
+    And the file ".git/ISSUE_EDITMSG" should not exist
+
+  Scenario: Create an issue with an empty title
+    When I run `hub issue create -m ""`
+    Then the stderr should contain exactly "Aborting due to empty issue title\n"
+    And the exit status should be 1
+
+  Scenario: Create an issue with an unknown milestone
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/milestones') {
+        json [{ :number => 3, :title => "v1.1" }]
+      }
+      """
+    When I run `hub issue create -m "Crash" -M v2.0`
+    Then the stderr should contain exactly "Error: milestone \"v2.0\" not found in mojombo/jekyll\n"
+    And the exit status should be 1
@@ -0,0 +1,101 @@
+Feature: hub label
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: List labels across pages
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/labels') {
+        if params[:page] == "2"
+          json [{ :name => "help wanted", :color => "008672", :description => nil }]
+        else
+          response.headers['Link'] = '<https://api.github.com/repos/mojombo/jekyll/labels?page=2>; rel="next"'
+          json [{ :name => "bug", :color => "d73a4a", :description => "Something isn't working" },
+                { :name => "docs", :color => "0075ca", :description => "" }]
+        end
+      }
+      """
+    When I successfully run `hub label`
+    Then the output should contain exactly:
+      """
+      bug          #d73a4a  Something isn't working
+      docs         #0075ca
+      help wanted  #008672\n
+      """
+
+  Scenario: Create a label
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/labels') {
+        assert :name => "needs review", :color => "ff0000", :description => "Waiting on a maintainer"
+        status 201
+        json :name => "needs review"
+      }
+      """
+    When I successfully run `hub label create "needs review" --color "#FF0000" --description "Waiting on a maintainer"`
+    Then the output should contain exactly "Created label needs review\n"
+
+  Scenario: Create a label with an invalid color
+    When I run `hub label create bug --color red`
+    Then the stderr should contain exactly "invalid color: red (use a hex code like ff0000)\n"
+    And the exit status should be 1
+
+  Scenario: Rename a label
+    Given the GitHub API server:
+      """
+      patch('/repos/mojombo/jekyll/labels/:name') {
+        halt 404 unless params[:name] == "help wanted"
+        assert :new_name => "contributions welcome"
+        json :name => "contributions welcome"
+      }
+      """
+    When I successfully run `hub label rename "help wanted" "contributions welcome"`
+    Then the output should contain exactly "Renamed label help wanted to contributions welcome\n"
+
+  Scenario: Delete a label
+    Given the GitHub API server:
+      """
+      delete('/repos/mojombo/jekyll/labels/wontfix') {
+        status 204
+      }
+      """
+    When I successfully run `hub label delete wontfix --yes`
+    Then the output should contain exactly "Deleted label wontfix\n"
+
+  Scenario: Delete a label without confirmation
+    When I run `hub label delete wontfix`
+    Then the stderr should contain exactly:
+      """
+      Aborted: use `--yes` to delete label "wontfix" without confirmation\n
+      """
+    And the exit status should be 1
+
+  Scenario: Copy labels from another repo
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/labels') {
+        json [{ :name => "Bug", :color => "ee0701", :description => nil },
+              { :name => "docs", :color => "0075ca", :description => "Documentation" }]
+      }
+      get('/repos/github/hub/labels') {
+        json [{ :name => "bug", :color => "d73a4a", :description => "Something isn't working" },
+              { :name => "docs", :color => "0075ca", :description => "Documentation" },
+              { :name => "feature", :color => "a2eeef", :description => nil }]
+      }
+      patch('/repos/mojombo/jekyll/labels/Bug') {
+        assert :color => "d73a4a", :description => "Something isn't working"
+        json :name => "Bug"
+      }
+      post('/repos/mojombo/jekyll/labels') {
+        assert :name => "feature", :color => "a2eeef"
+        status 201
+        json :name => "feature"
+      }
+      """
+    When I successfully run `hub label --copy-from github/hub --force`
+    Then the output should contain exactly:
+      """
+      Updated label Bug
+      Created label feature\n
+      """
@@ -0,0 +1,117 @@
+Feature: hub pr checkout <PULLREQ>
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: Pull request from the same repository
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :head => {
+          :label => 'mojombo:fixes',
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }, :base => {
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }
+      }
+      """
+    When I run `hub pr checkout 77`
+    Then "git fetch origin +refs/heads/fixes:refs/remotes/origin/fixes" should be run
+    And "git checkout --track -b fixes origin/fixes" should be run
+
+  Scenario: Pull request from a fork
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :head => {
+          :label => 'mislav:fixes',
+          :repo => { :name => 'jekyll', :full_name => 'mislav/jekyll', :private => false }
+        }, :base => {
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }
+      }
+      """
+    When I run `hub pr checkout https://github.com/mojombo/jekyll/pull/77`
+    Then "git remote add -f -t fixes mislav git://github.com/mislav/jekyll.git" should be run
+    And "git checkout --track -b mislav-fixes mislav/fixes" should be run
+
+  Scenario: Custom name for new branch
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :head => {
+          :label => 'mislav:fixes',
+          :repo => { :name => 'jekyll', :full_name => 'mislav/jekyll', :private => false }
+        }, :base => {
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }
+      }
+      """
+    And the "mislav" remote has url "git://github.com/mislav/jekyll.git"
+    When I run `hub pr checkout 77 fixes-from-mislav`
+    Then "git remote set-branches --add mislav fixes" should be run
+    And "git fetch mislav +refs/heads/fixes:refs/remotes/mislav/fixes" should be run
+    And "git checkout --track -b fixes-from-mislav mislav/fixes" should be run
+
+  Scenario: Maintainer can push to the fork
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :head => {
+          :label => 'mislav:fixes',
+          :repo => { :name => 'jekyll', :full_name => 'mislav/jekyll', :private => false }
+        }, :base => {
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }, :maintainer_can_modify => true
+      }
+      """
+    When I run `hub pr checkout 77`
+    Then "git checkout --track -b mislav-fixes mislav/fixes" should be run
+    And the git config "remote.mislav.push" should be "refs/heads/mislav-fixes:refs/heads/fixes"
+
+  Scenario: Deleted fork
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :head => { :label => 'unknown:fixes', :repo => nil },
+             :base => { :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false } }
+      }
+      """
+    When I run `hub pr checkout 77`
+    Then "git fetch origin refs/pull/77/head:refs/heads/pr-77" should be run
+    And "git checkout pr-77" should be run
+
+  Scenario: Fast-forward a previous checkout
+    Given I am on the "fixes" branch
+    And the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :head => {
+          :label => 'mojombo:fixes',
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }, :base => {
+          :repo => { :name => 'jekyll', :full_name => 'mojombo/jekyll', :private => false }
+        }
+      }
+      """
+    When I run `hub pr checkout 77`
+    Then "git fetch origin +refs/heads/fixes:refs/remotes/origin/fixes" should be run
+    And "git checkout fixes" should be run
+    And "git merge --ff-only origin/fixes" should be run
+
+  Scenario: Branch without a pull request
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        assert :head => "mojombo:feature"
+        json []
+      }
+      """
+    When I run `hub pr checkout feature`
+    Then the stderr should contain "fatal: no open pull request found for mojombo:feature"
+    And the exit status should be 1
+
+  Scenario: Invalid pull request URL
+    When I run `hub pr checkout https://github.com/mojombo/jekyll/issues/77`
+    Then the stderr should contain "Error: https://github.com/mojombo/jekyll/issues/77 is not a pull request number or URL"
+    And the exit status should be 1
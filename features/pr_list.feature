@@ -0,0 +1,81 @@
+Feature: hub pr list
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: List open pull requests
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        assert :state => nil
+        json [
+          { :number => 102, :title => "Fix typos", :user => { :login => "mislav" },
+            :html_url => "https://github.com/mojombo/jekyll/pull/102",
+            :head => { :ref => "typos", :label => "mislav:typos",
+                       :repo => { :full_name => "mislav/jekyll" } },
+            :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } },
+            :labels => [{ :name => "docs" }, { :name => "easy" }] },
+          { :number => 101, :title => "Speed up builds", :user => { :login => "parkr" },
+            :html_url => "https://github.com/mojombo/jekyll/pull/101",
+            :head => { :ref => "fast", :label => "mojombo:fast",
+                       :repo => { :full_name => "mojombo/jekyll" } },
+            :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } },
+            :labels => [] }
+        ]
+      }
+      """
+    When I successfully run `hub pr list`
+    Then the output should contain exactly:
+      """
+      102	Fix typos	mislav	mislav:typos -> master	docs, easy
+      101	Speed up builds	parkr	fast -> master\n
+      """
+
+  Scenario: Filters and limit
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        assert :state => "closed",
+               :base => "develop",
+               :head => "mojombo:fast",
+               :sort => "updated"
+        json [
+          { :number => 101, :title => "Speed up builds", :user => { :login => "parkr" },
+            :html_url => "https://github.com/mojombo/jekyll/pull/101",
+            :head => { :ref => "fast", :label => "mojombo:fast",
+                       :repo => { :full_name => "mojombo/jekyll" } },
+            :base => { :ref => "develop", :repo => { :full_name => "mojombo/jekyll" } },
+            :labels => [] },
+          { :number => 99, :title => "Older", :user => { :login => "parkr" },
+            :html_url => "https://github.com/mojombo/jekyll/pull/99",
+            :head => { :ref => "fast", :label => "mojombo:fast",
+                       :repo => { :full_name => "mojombo/jekyll" } },
+            :base => { :ref => "develop", :repo => { :full_name => "mojombo/jekyll" } },
+            :labels => [] }
+        ]
+      }
+      """
+    When I successfully run `hub pr list --state closed -b develop --head fast --sort updated -L 1`
+    Then the output should contain exactly "101	Speed up builds	parkr	fast -> develop\n"
+
+  Scenario: Custom format
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        json [
+          { :number => 102, :title => "Fix typos", :user => { :login => "mislav" },
+            :html_url => "https://github.com/mojombo/jekyll/pull/102",
+            :head => { :ref => "typos", :label => "mislav:typos",
+                       :repo => { :full_name => "mislav/jekyll" } },
+            :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } },
+            :labels => [{ :name => "docs" }] }
+        ]
+      }
+      """
+    When I successfully run `hub pr list --format "%I %U [%L] 100%%"`
+    Then the output should contain exactly "102 https://github.com/mojombo/jekyll/pull/102 [docs] 100%\n"
+
+  Scenario: Invalid state
+    When I run `hub pr list -s merged`
+    Then the stderr should contain exactly "invalid state: merged (use one of: open, closed, all)\n"
+    And the exit status should be 1
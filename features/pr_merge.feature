@@ -0,0 +1,144 @@
+Feature: hub pr merge
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: Squash and delete the head branch
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "clean",
+          :head => { :ref => "fixes", :label => "mojombo:fixes", :sha => "abc123",
+                     :repo => { :name => "jekyll", :owner => { :login => "mojombo" } } },
+          :base => { :ref => "master" }
+      }
+      put('/repos/mojombo/jekyll/pulls/77/merge') {
+        assert :merge_method => "squash",
+               :sha => "abc123",
+               :commit_title => "Fix things",
+               :commit_message => "For good."
+        json :merged => true, :sha => "def456"
+      }
+      delete('/repos/mojombo/jekyll/git/refs/heads/fixes') {
+        status 204
+      }
+      """
+    When I successfully run `hub pr merge 77 --squash -d -m "Fix things" -m "For good."`
+    Then the output should contain exactly:
+      """
+      Merged pull request #77 into master
+      Deleted branch mojombo:fixes\n
+      """
+
+  Scenario: Pull request for the current branch
+    Given I am on the "fixes" branch with upstream "origin/fixes"
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        assert :head => "mojombo:fixes"
+        json [{ :number => 77 }]
+      }
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "unstable",
+          :head => { :ref => "fixes", :label => "mojombo:fixes", :sha => "abc123",
+                     :repo => { :name => "jekyll", :owner => { :login => "mojombo" } } },
+          :base => { :ref => "master" }
+      }
+      put('/repos/mojombo/jekyll/pulls/77/merge') {
+        assert :merge_method => nil
+        json :merged => true, :sha => "def456"
+      }
+      """
+    When I successfully run `hub pr merge`
+    Then the output should contain exactly "Merged pull request #77 into master\n"
+
+  Scenario: Keep the branch of someone else's fork
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "clean",
+          :head => { :ref => "fixes", :label => "parkr:fixes", :sha => "abc123",
+                     :repo => { :name => "jekyll", :owner => { :login => "parkr" } } },
+          :base => { :ref => "master" }
+      }
+      put('/repos/mojombo/jekyll/pulls/77/merge') {
+        json :merged => true, :sha => "def456"
+      }
+      """
+    When I successfully run `hub pr merge 77 --delete-branch`
+    Then the output should contain exactly "Merged pull request #77 into master\n"
+    And the stderr should contain exactly "Not deleting parkr:fixes as it belongs to someone else's fork\n"
+
+  Scenario: Blocked by required status checks
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "blocked",
+          :head => { :ref => "fixes", :label => "mojombo:fixes", :sha => "abc123" },
+          :base => { :ref => "master" }
+      }
+      get('/repos/mojombo/jekyll/commits/abc123/status') {
+        json :state => "failure", :statuses => [
+          { :context => "ci/travis", :state => "failure" },
+          { :context => "ci/lint", :state => "success" }
+        ]
+      }
+      """
+    When I run `hub pr merge 77`
+    Then the stderr should contain exactly:
+      """
+      Aborted: pull request #77 is blocked by required reviews or status checks
+        ci/travis: failure
+      (use `--admin` to merge anyway)\n
+      """
+    And the exit status should be 1
+
+  Scenario: Merge a blocked pull request as admin
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "blocked",
+          :head => { :ref => "fixes", :label => "mojombo:fixes", :sha => "abc123" },
+          :base => { :ref => "master" }
+      }
+      put('/repos/mojombo/jekyll/pulls/77/merge') {
+        assert :merge_method => "rebase"
+        json :merged => true, :sha => "def456"
+      }
+      """
+    When I successfully run `hub pr merge 77 --rebase --admin`
+    Then the output should contain exactly "Merged pull request #77 into master\n"
+
+  Scenario: Conflicts
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "dirty",
+          :head => { :ref => "fixes", :label => "mojombo:fixes", :sha => "abc123" },
+          :base => { :ref => "master" }
+      }
+      """
+    When I run `hub pr merge 77 --admin`
+    Then the stderr should contain exactly "Aborted: pull request #77 has conflicts with master\n"
+    And the exit status should be 1
+
+  Scenario: Head branch changed in the meantime
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :state => "open", :merged => false, :mergeable_state => "clean",
+          :head => { :ref => "fixes", :label => "mojombo:fixes", :sha => "abc123" },
+          :base => { :ref => "master" }
+      }
+      put('/repos/mojombo/jekyll/pulls/77/merge') {
+        status 409
+        json :message => "Head branch was modified. Review and try the merge again."
+      }
+      """
+    When I run `hub pr merge 77`
+    Then the stderr should contain exactly:
+      """
+      Error merging pull request: Conflict (HTTP 409)
+      Head branch was modified. Review and try the merge again.\n
+      """
+    And the exit status should be 1
@@ -0,0 +1,125 @@
+Feature: hub pr show
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: Show an open pull request
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :title => "Fix typos", :state => "open", :merged => false,
+          :mergeable => true, :user => { :login => "mislav" },
+          :body => "## Summary\r\n\r\nFixes **all** typos in `README`, see [docs](http://jekyllrb.com).<!-- hidden -->",
+          :html_url => "https://github.com/mojombo/jekyll/pull/77",
+          :head => { :ref => "typos", :label => "mislav:typos", :sha => "abc123",
+                     :repo => { :full_name => "mislav/jekyll" } },
+          :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } }
+      }
+      get('/repos/mojombo/jekyll/commits/abc123/status') {
+        json :state => "success", :total_count => 2, :statuses => []
+      }
+      """
+    When I successfully run `hub pr show 77`
+    Then the output should contain exactly:
+      """
+      Fix typos #77
+      Open - mislav wants to merge mislav:typos into master
+      CI: success, mergeable: yes
+
+      Summary
+
+      Fixes all typos in README, see docs (http://jekyllrb.com).
+
+      https://github.com/mojombo/jekyll/pull/77\n
+      """
+
+  Scenario: Show the pull request for a branch
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        assert :head => "mislav:typos"
+        json [{ :number => 77 }]
+      }
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :title => "Fix typos", :state => "open", :merged => false,
+          :mergeable => true, :user => { :login => "mislav" }, :body => "",
+          :html_url => "https://github.com/mojombo/jekyll/pull/77",
+          :head => { :ref => "typos", :label => "mislav:typos", :sha => "abc123",
+                     :repo => { :full_name => "mislav/jekyll" } },
+          :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } }
+      }
+      get('/repos/mojombo/jekyll/commits/abc123/status') {
+        json :state => "success", :total_count => 2, :statuses => []
+      }
+      """
+    When I successfully run `hub pr show mislav:typos`
+    Then the output should contain:
+      """
+      Fix typos #77
+      Open - mislav wants to merge mislav:typos into master
+      """
+
+  Scenario: Show a merged pull request with comments
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :title => "Fix typos", :state => "closed", :merged => true,
+          :user => { :login => "mislav" }, :body => nil,
+          :html_url => "https://github.com/mojombo/jekyll/pull/77",
+          :head => { :ref => "typos", :label => "mojombo:typos", :sha => "abc123",
+                     :repo => { :full_name => "mojombo/jekyll" } },
+          :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } }
+      }
+      get('/repos/mojombo/jekyll/issues/77/comments') {
+        json [{ :user => { :login => "parkr" }, :created_at => "2013-05-01T12:00:00Z",
+                :body => "Thanks!" }]
+      }
+      """
+    When I successfully run `hub pr show 77 --comments`
+    Then the output should contain exactly:
+      """
+      Fix typos #77
+      Merged - mislav merged typos into master
+
+      parkr commented on 2013-05-01:
+      Thanks!
+
+      https://github.com/mojombo/jekyll/pull/77\n
+      """
+
+  Scenario: Custom format
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :title => "Fix typos", :state => "closed", :merged => false,
+          :user => { :login => "mislav" },
+          :html_url => "https://github.com/mojombo/jekyll/pull/77",
+          :head => { :ref => "typos", :label => "mislav:typos", :repo => nil },
+          :base => { :ref => "master", :repo => { :full_name => "mojombo/jekyll" } }
+      }
+      """
+    When I successfully run `hub pr show 77 -f "%S %U"`
+    Then the output should contain exactly "closed https://github.com/mojombo/jekyll/pull/77\n"
+
+  Scenario: Open in a browser
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls/77') {
+        json :number => 77, :html_url => "https://github.com/mojombo/jekyll/pull/77"
+      }
+      """
+    When I successfully run `hub pr show 77 --web`
+    Then "open https://github.com/mojombo/jekyll/pull/77" should be run
+
+  Scenario: No pull request for the current branch
+    Given I am on the "typos" branch
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/pulls') {
+        assert :head => "mojombo:typos"
+        json []
+      }
+      """
+    When I run `hub pr show`
+    Then the stderr should contain exactly "fatal: no open pull request found for mojombo:typos\n"
+    And the exit status should be 1
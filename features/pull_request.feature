@@ -210,6 +210,121 @@ Feature: hub pull-request
     Then the output should contain exactly "https://github.com/mislav/coral/pull/12\n"
     And the file ".git/PULLREQ_EDITMSG" should not exist
 
+  Scenario: Title and body from multiple command-line arguments
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        assert :title => 'I am just a pull',
+               :body  => "A little pull\n\nWith a second paragraph"
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    When I successfully run `hub pull-request -m "I am just a pull" -m "A little pull" -m "With a second paragraph"`
+    Then the output should contain exactly "https://github.com/mislav/coral/pull/12\n"
+
+  Scenario: Command-line message takes precedence over file
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        assert :title => 'Title from argument', :body => nil
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    And a file named "pullreq-msg" with:
+      """
+      Title from file
+      """
+    When I successfully run `hub pull-request -F pullreq-msg -m "Title from argument"`
+    Then the output should contain exactly "https://github.com/mislav/coral/pull/12\n"
+
+  Scenario: Message file with CRLF line endings
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        assert :title => 'Title from Windows',
+               :body  => "First line\nSecond line"
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    And a file named "pullreq-msg" with CRLF line endings:
+      """
+      Title from Windows
+
+      First line
+      Second line
+      """
+    When I successfully run `hub pull-request -F pullreq-msg`
+    Then the output should contain exactly "https://github.com/mislav/coral/pull/12\n"
+
+  Scenario: Empty title from file
+    Given an empty file named "pullreq-msg"
+    When I run `hub pull-request -F pullreq-msg`
+    Then the stderr should contain exactly "Aborting due to empty pull request title\n"
+    And the exit status should be 1
+
+  Scenario: Open the new pull request in a browser
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    When I successfully run `hub pull-request -m hello -o`
+    Then there should be no output
+    And "open https://github.com/mislav/coral/pull/12" should be run
+
+  Scenario: Copy the URL of the new pull request
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    When I successfully run `hub pull-request -m hello --copy`
+    Then there should be no output
+    And the clipboard should contain "https://github.com/mislav/coral/pull/12"
+
+  Scenario: Open and copy the URL of the new pull request
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    When I successfully run `hub pull-request -m hello -o -c`
+    Then there should be no output
+    And "open https://github.com/mislav/coral/pull/12" should be run
+    And the clipboard should contain "https://github.com/mislav/coral/pull/12"
+
+  Scenario: Push the current branch to own fork first
+    Given the "origin" remote has url "git://github.com/mojombo/coral.git"
+    And the "mislav" remote has url "git@github.com:mislav/coral.git"
+    And I am on the "feature" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/coral/pulls') {
+        assert :base => 'master',
+               :head => 'mislav:feature'
+        json :html_url => "https://github.com/mojombo/coral/pull/12"
+      }
+      """
+    When I successfully run `hub pull-request -p -m hello`
+    Then "git push -u mislav feature" should be run
+    And the output should contain exactly "https://github.com/mojombo/coral/pull/12\n"
+
+  Scenario: Push the current branch to origin without a fork
+    Given I am on the "feature" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mislav/coral/pulls') {
+        assert :head => 'feature'
+        json :html_url => "https://github.com/mislav/coral/pull/12"
+      }
+      """
+    When I successfully run `hub pull-request --push -m hello`
+    Then "git push -u origin feature" should be run
+    And the output should contain exactly "https://github.com/mislav/coral/pull/12\n"
+
   Scenario: Error when implicit head is the same as base
     Given I am on the "master" branch with upstream "origin/master"
     When I run `hub pull-request`
@@ -224,7 +339,7 @@ Feature: hub pull-request
     Given the GitHub API server:
       """
       post('/repos/mislav/coral/pulls') {
-        assert :head => 'mislav:feature'
+        assert :head => 'feature'
         json :html_url => "the://url"
       }
       """
@@ -262,7 +377,7 @@ Feature: hub pull-request
       """
       post('/repos/mislav/coral/pulls') {
         assert :base => 'develop',
-               :head => 'mislav:master'
+               :head => 'master'
         json :html_url => "the://url"
       }
       """
@@ -293,6 +408,73 @@ Feature: hub pull-request
     When I successfully run `hub pull-request -b mojombo/coralify:develop -m message`
     Then the output should contain exactly "the://url\n"
 
+  Scenario: Explicit base and head in the same fork
+    Given I am on the "master" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/coral/pulls') {
+        assert :base => 'develop',
+               :head => 'feature'
+        json :html_url => "the://url"
+      }
+      """
+    When I successfully run `hub pull-request --base mojombo:develop --head mojombo:feature -m message`
+    Then the output should contain exactly "the://url\n"
+
+  Scenario: Explicit base and head in different forks
+    Given I am on the "master" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/coral/pulls') {
+        assert :base => 'develop',
+               :head => 'mislav:feature'
+        json :html_url => "the://url"
+      }
+      """
+    When I successfully run `hub pull-request -b mojombo:develop -h mislav:feature -m message`
+    Then the output should contain exactly "the://url\n"
+
+  Scenario: Explicit head owner without branch name
+    Given I am on the "feature" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/coral/pulls') {
+        assert :base => 'master',
+               :head => 'mislav:feature'
+        json :html_url => "the://url"
+      }
+      """
+    When I successfully run `hub pull-request -b mojombo:master --head mislav: -m message`
+    Then the output should contain exactly "the://url\n"
+
+  Scenario: Explicit head as a bare owner of a remote
+    Given the "upstream" remote has url "git://github.com/mojombo/coral.git"
+    And I am on the "feature" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/coral/pulls') {
+        assert :base => 'master',
+               :head => 'mislav:feature'
+        json :html_url => "the://url"
+      }
+      """
+    When I successfully run `hub pull-request -b mojombo:master -h mislav -m message`
+    Then the output should contain exactly "the://url\n"
+
+  Scenario: Explicit head as a bare remote name
+    Given the "upstream" remote has url "git://github.com/mojombo/coral.git"
+    And I am on the "feature" branch
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/coral/pulls') {
+        assert :base => 'master',
+               :head => 'mislav:feature'
+        json :html_url => "the://url"
+      }
+      """
+    When I successfully run `hub pull-request -b mojombo:master -h origin -m message`
+    Then the output should contain exactly "the://url\n"
+
   Scenario: Error when there are unpushed commits
     Given I am on the "feature" branch with upstream "origin/feature"
     When I make 2 commits
@@ -308,7 +490,7 @@ Feature: hub pull-request
     Given the GitHub API server:
       """
       post('/repos/mislav/coral/pulls') {
-        assert :head => 'mislav:feature'
+        assert :head => 'feature'
         json :html_url => "the://url"
       }
       """
@@ -371,7 +553,7 @@ Feature: hub pull-request
     Given the GitHub API server:
       """
       post('/repos/mislav/coral/pulls') {
-        assert :head => 'mislav:feature'
+        assert :head => 'feature'
         json :html_url => "the://url"
       }
       """
@@ -0,0 +1,322 @@
+Feature: hub release
+  Background:
+    Given I am in "git://github.com/mojombo/jekyll.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: List releases
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases') {
+        json [
+          { :tag_name => "v1.2.0-rc1", :name => "Release candidate", :prerelease => true, :draft => false },
+          { :tag_name => "v1.1.0", :name => "", :prerelease => false, :draft => false },
+          { :tag_name => "v1.3.0", :name => "Next", :prerelease => false, :draft => true },
+        ]
+      }
+      """
+    When I successfully run `hub release`
+    Then the output should contain exactly:
+      """
+      v1.2.0-rc1  Release candidate  (prerelease)
+      v1.1.0      v1.1.0\n
+      """
+
+  Scenario: Include drafts and exclude prereleases
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases') {
+        json [
+          { :tag_name => "v1.3.0", :name => "Next", :prerelease => false, :draft => true },
+          { :tag_name => "v1.2.0-rc1", :name => "Release candidate", :prerelease => true, :draft => false },
+          { :tag_name => "v1.1.0", :name => "Stable", :prerelease => false, :draft => false },
+        ]
+      }
+      """
+    When I successfully run `hub release --include-drafts --exclude-prereleases`
+    Then the output should contain exactly:
+      """
+      v1.3.0  Next  (draft)
+      v1.1.0  Stable\n
+      """
+
+  Scenario: Custom format and limit
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases') {
+        json [
+          { :tag_name => "v1.2.0", :published_at => "2013-06-01T10:00:00Z", :draft => false,
+            :html_url => "https://github.com/mojombo/jekyll/releases/tag/v1.2.0" },
+          { :tag_name => "v1.1.0", :published_at => "2013-05-01T10:00:00Z", :draft => false,
+            :html_url => "https://github.com/mojombo/jekyll/releases/tag/v1.1.0" },
+        ]
+      }
+      """
+    When I successfully run `hub release -L 1 --format "%T %pI %U%n"`
+    Then the output should contain exactly:
+      """
+      v1.2.0 2013-06-01T10:00:00Z https://github.com/mojombo/jekyll/releases/tag/v1.2.0\n
+      """
+
+  Scenario: Show a release
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :tag_name => "v1.2.0", :name => "Jekyll 1.2", :draft => false, :prerelease => false,
+          :published_at => "2013-06-01T10:00:00Z",
+          :body => "Lots of **fixes**.",
+          :html_url => "https://github.com/mojombo/jekyll/releases/tag/v1.2.0",
+          :assets => [
+            { :name => "jekyll-1.2.0.gem", :size => 94_310, :download_count => 12 },
+            { :name => "checksums.txt", :size => 180, :download_count => 1 },
+          ]
+      }
+      """
+    When I successfully run `hub release show v1.2.0`
+    Then the output should contain exactly:
+      """
+      Jekyll 1.2 (v1.2.0)
+      Published on 2013-06-01
+
+      Lots of fixes.
+
+      Assets:
+        jekyll-1.2.0.gem    92.1 KB  12 downloads
+        checksums.txt         180 B  1 download
+
+      https://github.com/mojombo/jekyll/releases/tag/v1.2.0\n
+      """
+
+  Scenario: Open a release in the browser
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :tag_name => "v1.2.0",
+          :html_url => "https://github.com/mojombo/jekyll/releases/tag/v1.2.0"
+      }
+      """
+    When I successfully run `hub release show v1.2.0 --web`
+    Then there should be no output
+    And "open https://github.com/mojombo/jekyll/releases/tag/v1.2.0" should be run
+
+  Scenario: Show a tag without a release
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2') {
+        status 404
+        json :message => "Not Found"
+      }
+      get('/repos/mojombo/jekyll/releases') {
+        json [{ :tag_name => "v0.9.0" }, { :tag_name => "v1.2.0" }, { :tag_name => "v1.1.0" }, { :tag_name => "v1.2.1" }]
+      }
+      """
+    When I run `hub release show v1.2`
+    Then the exit status should be 1
+    And the stderr should contain exactly:
+      """
+      Error: no release found for tag v1.2
+      Did you mean one of these?
+          v1.2.0
+          v1.2.1
+          v1.1.0\n
+      """
+
+  Scenario: Create a release with assets
+    Given a file named "hub.tgz" with:
+      """
+      TARBALL
+      """
+    And a file named "hub.zip" with:
+      """
+      ZIPBALL
+      """
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/releases') {
+        assert :tag_name => "v1.2.0", :name => "Jekyll 1.2", :body => "Lots of fixes.",
+               :draft => true, :prerelease => false, :target_commitish => "release-1.2"
+        status 201
+        json :id => 1, :tag_name => "v1.2.0",
+          :upload_url => "https://uploads.github.com/repos/mojombo/jekyll/releases/1/assets{?name,label}",
+          :html_url => "https://github.com/mojombo/jekyll/releases/tag/untagged-123"
+      }
+      post('/repos/mojombo/jekyll/releases/1/assets') {
+        halt 400 unless request.body.read.strip == { "hub.tgz" => "TARBALL", "hub.zip" => "ZIPBALL" }[params[:name]]
+        halt 400 unless params[:label] == { "hub.tgz" => "Linux", "hub.zip" => nil }[params[:name]]
+        status 201
+        json :name => params[:name]
+      }
+      patch('/repos/mojombo/jekyll/releases/1') {
+        assert :draft => false
+        json :id => 1, :html_url => "https://github.com/mojombo/jekyll/releases/tag/v1.2.0"
+      }
+      """
+    When I successfully run `hub release create v1.2.0 -m "Jekyll 1.2" -m "Lots of fixes." --commitish release-1.2 -a hub.tgz#Linux -a hub.zip`
+    Then the output should contain exactly "https://github.com/mojombo/jekyll/releases/tag/v1.2.0\n"
+    And the stderr should contain "Attached hub.tgz"
+    And the stderr should contain "Attached hub.zip"
+
+  Scenario: Failed asset upload leaves the release as a draft
+    Given a file named "hub.tgz" with:
+      """
+      TARBALL
+      """
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/releases') {
+        assert :draft => true
+        status 201
+        json :id => 1, :tag_name => "v1.2.0",
+          :upload_url => "https://uploads.github.com/repos/mojombo/jekyll/releases/1/assets{?name,label}"
+      }
+      post('/repos/mojombo/jekyll/releases/1/assets') {
+        status 502
+      }
+      """
+    When I run `hub release create v1.2.0 -m "Jekyll 1.2" -a hub.tgz#Linux`
+    Then the exit status should be 1
+    And the stderr should contain exactly:
+      """
+      Error uploading hub.tgz: Bad Gateway (HTTP 502)
+      The release was left as a draft. To retry, run:
+        hub release edit v1.2.0 -a hub.tgz#Linux --publish\n
+      """
+
+  Scenario: Create a prerelease with generated notes in the text editor
+    Given the git commit editor is "true"
+    Given the GitHub API server:
+      """
+      post('/repos/mojombo/jekyll/releases/generate-notes') {
+        assert :tag_name => "v1.3.0-rc1"
+        json :name => "v1.3.0-rc1", :body => "* Faster builds by @parkr"
+      }
+      post('/repos/mojombo/jekyll/releases') {
+        assert :tag_name => "v1.3.0-rc1", :name => "v1.3.0-rc1", :body => "* Faster builds by @parkr",
+               :draft => false, :prerelease => true
+        status 201
+        json :html_url => "https://github.com/mojombo/jekyll/releases/tag/v1.3.0-rc1"
+      }
+      """
+    When I successfully run `hub release create v1.3.0-rc1 --prerelease --generate-notes`
+    Then the output should contain exactly "https://github.com/mojombo/jekyll/releases/tag/v1.3.0-rc1\n"
+    And the file ".git/RELEASE_EDITMSG" should not exist
+
+  Scenario: Edit only the given fields of a release
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :id => 1, :tag_name => "v1.2.0", :draft => false, :prerelease => true
+      }
+      patch('/repos/mojombo/jekyll/releases/1') {
+        halt 400 unless params.keys == ["prerelease"]
+        assert :prerelease => false
+        json :id => 1
+      }
+      """
+    When I successfully run `hub release edit v1.2.0 --no-prerelease`
+    Then the output should contain exactly "Updated release v1.2.0\n"
+
+  Scenario: Attach assets to a draft before publishing it
+    Given a file named "hub.tgz" with:
+      """
+      TARBALL
+      """
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        status 404
+        json :message => "Not Found"
+      }
+      get('/repos/mojombo/jekyll/releases') {
+        json [{ :id => 1, :tag_name => "v1.2.0", :draft => true,
+                :upload_url => "https://uploads.github.com/repos/mojombo/jekyll/releases/1/assets{?name,label}" }]
+      }
+      post('/repos/mojombo/jekyll/releases/1/assets') {
+        halt 400 unless params[:name] == "hub.tgz"
+        status 201
+        json :name => "hub.tgz"
+      }
+      patch('/repos/mojombo/jekyll/releases/1') {
+        assert :draft => false
+        json :id => 1
+      }
+      """
+    When I successfully run `hub release edit v1.2.0 -a hub.tgz --publish`
+    Then the output should contain exactly "Updated release v1.2.0\n"
+    And the stderr should contain "Attached hub.tgz (1/1)"
+
+  Scenario: Delete a release and its tag
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :id => 1, :tag_name => "v1.2.0"
+      }
+      delete('/repos/mojombo/jekyll/releases/1') {
+        status 204
+      }
+      delete('/repos/mojombo/jekyll/git/refs/tags/v1.2.0') {
+        status 204
+      }
+      """
+    When I successfully run `hub release delete v1.2.0 --with-tag --yes`
+    Then the output should contain exactly "Deleted release v1.2.0 and tag\n"
+
+  Scenario: Delete a release without confirmation
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :id => 1, :tag_name => "v1.2.0"
+      }
+      """
+    When I run `hub release delete v1.2.0`
+    Then the stderr should contain exactly:
+      """
+      Aborted: use `--yes` to delete release v1.2.0 without confirmation\n
+      """
+    And the exit status should be 1
+
+  Scenario: Download release assets matching a pattern
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :id => 1, :tag_name => "v1.2.0", :assets => [
+          { :name => "jekyll-linux.tgz", :url => "https://api.github.com/repos/mojombo/jekyll/releases/assets/11" },
+          { :name => "jekyll-windows.zip", :url => "https://api.github.com/repos/mojombo/jekyll/releases/assets/12" },
+        ]
+      }
+      get('/repos/mojombo/jekyll/releases/assets/11') {
+        halt 415 unless request.env['HTTP_ACCEPT'] == 'application/octet-stream'
+        redirect 'https://objects.githubusercontent.com/jekyll-linux.tgz', 302
+      }
+      get('/jekyll-linux.tgz') {
+        halt 401 if request.env['HTTP_AUTHORIZATION']
+        content_type 'application/octet-stream'
+        'TARBALL'
+      }
+      """
+    When I successfully run `hub release download v1.2.0 --pattern "*.tgz" --dir dist`
+    Then the stderr should contain exactly "Downloaded dist/jekyll-linux.tgz\n"
+    And the file "dist/jekyll-linux.tgz" should contain exactly:
+      """
+      TARBALL
+      """
+    And the file "dist/jekyll-windows.zip" should not exist
+
+  Scenario: Refuse to overwrite downloaded assets
+    Given a file named "jekyll-linux.tgz" with:
+      """
+      OLD
+      """
+    Given the GitHub API server:
+      """
+      get('/repos/mojombo/jekyll/releases/tags/v1.2.0') {
+        json :id => 1, :tag_name => "v1.2.0", :assets => [
+          { :name => "jekyll-linux.tgz", :url => "https://api.github.com/repos/mojombo/jekyll/releases/assets/11" },
+        ]
+      }
+      """
+    When I run `hub release download v1.2.0`
+    Then the stderr should contain exactly:
+      """
+      Aborted: ./jekyll-linux.tgz already exists (use `--clobber` to overwrite)\n
+      """
+    And the exit status should be 1
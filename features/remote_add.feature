@@ -90,6 +90,11 @@ Feature: hub remote add
     Then the url for "mm" should be "git://github.com/mislav/dotfilez.js.git"
     And there should be no output
 
+  Scenario: Add origin remote with owner and repo name
+    When I successfully run `hub remote add origin mislav/dotfilez.js`
+    Then the url for "origin" should be "git://github.com/mislav/dotfilez.js.git"
+    And there should be no output
+
   Scenario: Add named private remote
     When I successfully run `hub remote add -p mm mislav`
     Then the url for "mm" should be "git@github.com:mislav/dotfiles.git"
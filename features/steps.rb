@@ -97,6 +97,8 @@ Given(/^the current dir is not a repo$/) do
 end
 
 Given(/^the GitHub API server:$/) do |endpoints_str|
+  # lets endpoints refer to commits of the local repo, e.g. `local_sha['feature']`
+  local_sha = lambda { |ref| run_silent("git rev-parse #{ref}").chomp }
   @server = Hub::LocalServer.start_sinatra do
     eval endpoints_str, binding
   end
@@ -126,6 +128,10 @@ Then(/^there should be no output$/) do
   assert_exact_output('', all_output)
 end
 
+Then(/^the clipboard should contain "([^"]*)"$/) do |text|
+  File.read(File.join(ENV['HOME'], '.clipboard')).should eql(text)
+end
+
 Then(/^the git command should be unchanged$/) do
   @commands.should_not be_empty
   assert_command_run @commands.last.sub(/^hub\b/, 'git')
@@ -136,6 +142,11 @@ Then(/^the url for "([^"]*)" should be "([^"]*)"$/) do |name, url|
   found.should eql(url)
 end
 
+Then(/^the git config "([^"]*)" should be "([^"]*)"$/) do |key, value|
+  found = run_silent %(git config --get-all #{key})
+  found.should eql(value)
+end
+
 Then(/^the "([^"]*)" submodule url should be "([^"]*)"$/) do |name, url|
   found = run_silent %(git config --get-all submodule."#{name}".url)
   found.should eql(url)
@@ -153,6 +164,10 @@ Then(/^the file "([^"]*)" should have mode "([^"]*)"$/) do |file, expected_mode|
   end
 end
 
+Given(/^a file named "([^"]+)" with CRLF line endings:$/) do |file, content|
+  write_file(file, content.gsub("\n", "\r\n") + "\r\n")
+end
+
 Given(/^the file named "(.+?)" is older than hub source$/) do |file|
   prep_for_fs_check do
     time = File.mtime(File.expand_path('../../lib/hub/commands.rb', __FILE__)) - 60
@@ -27,6 +27,8 @@ Before do
   set_env 'HUB_TEST_HOST', '127.0.0.1:0'
   # ensure we use fakebin `open` to test browsing
   set_env 'BROWSER', 'open'
+  # ensure we use fakebin `xclip` or `pbcopy` to test copying
+  set_env 'WAYLAND_DISPLAY', nil
   # sabotage opening a commit message editor interactively
   set_env 'GIT_EDITOR', 'false'
 
@@ -0,0 +1,124 @@
+Feature: hub sync
+  Background:
+    Given I am in "git://github.com/mislav/dotfiles.git" git repo
+    And I am "mislav" on github.com with OAuth token "OTOKEN"
+
+  Scenario: Fast-forward a branch that is behind its upstream
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I make 2 commits
+    And I successfully run `git update-ref refs/remotes/origin/feature HEAD`
+    And I successfully run `git checkout --quiet master`
+    And I successfully run `git update-ref refs/heads/feature HEAD`
+    When I successfully run `hub sync`
+    Then "git fetch --prune --quiet --all" should be run
+    And the output should contain exactly "Fast-forwarded feature to origin/feature\n"
+
+  Scenario: Fast-forward the current branch
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I make a commit
+    And I successfully run `git update-ref refs/remotes/origin/feature HEAD`
+    And I successfully run `git reset --quiet --hard HEAD^`
+    When I successfully run `hub sync`
+    Then the output should contain exactly "Fast-forwarded feature to origin/feature\n"
+
+  Scenario: Warn about a diverged branch
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I make a commit
+    And I successfully run `git update-ref refs/remotes/origin/feature HEAD`
+    And I successfully run `git reset --quiet --hard HEAD^`
+    And I make a commit
+    When I successfully run `hub sync`
+    Then the output should contain exactly "Warning: feature has diverged from origin/feature; not updating it\n"
+
+  Scenario: Delete a branch whose pull request was merged
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I successfully run `git checkout --quiet master`
+    And I successfully run `git update-ref -d refs/remotes/origin/feature`
+    Given the GitHub API server:
+      """
+      feature_sha = local_sha['feature']
+      get('/repos/mislav/dotfiles/pulls') {
+        assert :head => "mislav:feature", :state => "closed"
+        json [{ :number => 12, :merged_at => nil },
+              { :number => 13, :merged_at => "2020-01-05T00:00:00Z", :head => { :sha => feature_sha } }]
+      }
+      """
+    When I successfully run `hub sync`
+    Then the output should contain exactly "Deleted feature (pull request #13 was merged)\n"
+    And "git branch --quiet -D feature" should be run
+
+  Scenario: Switch to the default branch before deleting the current branch
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I successfully run `git update-ref -d refs/remotes/origin/feature`
+    Given the GitHub API server:
+      """
+      feature_sha = local_sha['feature']
+      get('/repos/mislav/dotfiles/pulls') {
+        json [{ :number => 13, :merged_at => "2020-01-05T00:00:00Z", :head => { :sha => feature_sha } }]
+      }
+      get('/repos/mislav/dotfiles') {
+        json :default_branch => "master"
+      }
+      """
+    When I successfully run `hub sync`
+    Then the output should contain exactly "Deleted feature (pull request #13 was merged)\n"
+    And "git checkout --quiet master" should be run
+
+  Scenario: Keep a branch whose pull request isn't merged
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I successfully run `git checkout --quiet master`
+    And I successfully run `git update-ref -d refs/remotes/origin/feature`
+    Given the GitHub API server:
+      """
+      get('/repos/mislav/dotfiles/pulls') {
+        json [{ :number => 12, :merged_at => nil }]
+      }
+      """
+    When I successfully run `hub sync`
+    Then the output should contain exactly "Warning: feature was deleted from origin but its pull request isn't merged; not deleting it\n"
+    And "git branch --quiet -D feature" should not be run
+
+  Scenario: Keep a merged branch with commits made after the merge
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I successfully run `git update-ref -d refs/remotes/origin/feature`
+    Given the GitHub API server:
+      """
+      feature_sha = local_sha['feature']
+      get('/repos/mislav/dotfiles/pulls') {
+        json [{ :number => 13, :merged_at => "2020-01-05T00:00:00Z", :head => { :sha => feature_sha } }]
+      }
+      """
+    And I make a commit
+    When I successfully run `hub sync`
+    Then the output should contain exactly "Warning: feature has commits that weren't in pull request #13; not deleting it\n"
+    And "git branch --quiet -D feature" should not be run
+    And "git checkout --quiet master" should not be run
+
+  Scenario: Dry run
+    Given I am on the "feature" branch with upstream "origin/feature"
+    And I successfully run `git checkout --quiet master`
+    And I successfully run `git update-ref -d refs/remotes/origin/feature`
+    And I am on the "topic" branch with upstream "origin/topic"
+    And I make a commit
+    And I successfully run `git update-ref refs/remotes/origin/topic HEAD`
+    And I successfully run `git reset --quiet --hard HEAD^`
+    Given the GitHub API server:
+      """
+      feature_sha = local_sha['feature']
+      get('/repos/mislav/dotfiles/pulls') {
+        json [{ :number => 13, :merged_at => "2020-01-05T00:00:00Z", :head => { :sha => feature_sha } }]
+      }
+      """
+    When I successfully run `hub sync --dry-run`
+    Then "git fetch --quiet --all" should be run
+    And the output should contain exactly:
+      """
+      Would delete feature (pull request #13 was merged)
+      Would fast-forward topic to origin/topic\n
+      """
+    And "git branch --quiet -D feature" should not be run
+
+  Scenario: Leave branches alone that were never pushed
+    Given I am on the "local" branch
+    When I successfully run `hub sync`
+    Then there should be no output
@@ -33,10 +33,13 @@ module Hub
       @chain.size > 1
     end
 
-    # Returns an array of all commands.
+    # Returns an array of all commands. When all arguments were removed,
+    # only the callbacks are left.
     def commands
       chain = @chain.dup
-      chain[chain.index(nil)] = self.to_exec
+      if empty? then chain.delete(nil)
+      else chain[chain.index(nil)] = self.to_exec
+      end
       chain
     end
 
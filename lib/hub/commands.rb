@@ -38,7 +38,24 @@ module Hub
     OWNER_RE = /[a-zA-Z0-9][a-zA-Z0-9-]*/
     NAME_WITH_OWNER_RE = /^(?:#{NAME_RE}|#{OWNER_RE}\/#{NAME_RE})$/
 
-    CUSTOM_COMMANDS = %w[alias create browse compare fork pull-request ci-status]
+    CUSTOM_COMMANDS = %w[alias create browse compare fork pull-request pr issue label release ci-status sync api]
+
+    PULLREQ_STATES = %w[open closed all]
+    PULLREQ_SORTS = %w[created updated popularity long-running]
+    ISSUE_SORTS = %w[created updated comments]
+    # terminal color codes for issue and pull request states
+    STATE_COLORS = {'open' => 32, 'draft' => 90, 'closed' => 31, 'merged' => 35}
+
+    BROWSE_TARGETS = %w[issues pulls wiki releases]
+
+    # `git push` flags whose value can be given as the next argument
+    PUSH_VALUE_FLAGS = %w[-o --push-option --repo --receive-pack --exec --recurse-submodules]
+
+    CI_GLYPHS = {
+      'success' => [0x2714].pack('U'), 'failure' => [0x2716].pack('U'),
+      'error'   => [0x2716].pack('U'), 'pending' => [0x25CF].pack('U'),
+    }
+    CI_COLORS = {'success' => 32, 'failure' => 31, 'error' => 31, 'pending' => 33}
 
     def run(args)
       slurp_global_flags(args)
@@ -67,6 +84,9 @@ module Hub
         raise
       end
     rescue Context::FatalError => err
+      if ENV['HUB_VERBOSE'] and original = err.original_error
+        $stderr.puts "#{original.class}: #{original.message}"
+      end
       abort "fatal: #{err.message}"
     end
 
@@ -75,8 +95,16 @@ module Hub
     # $ hub ci-status 6f6d9797f9d6e56c3da623a97cfc3f45daf9ae5f
     # $ hub ci-status master
     # $ hub ci-status origin/master
+    # $ hub ci-status -v
+    # $ hub ci-status --format '%S %t%n' --pending-ok
     def ci_status(args)
       args.shift
+      verbose = args.delete('-v') || args.delete('--verbose')
+      pending_ok = args.delete('--pending-ok')
+      if idx = args.index('-f') || args.index('--format')
+        format = args.delete_at(idx + 1)
+        args.delete_at(idx)
+      end
       ref = args.words.first || 'HEAD'
 
       unless head_project = local_repo.current_project
@@ -87,29 +115,189 @@ module Hub
         abort "Aborted: no revision could be determined from '#{ref}'"
       end
 
-      statuses = api_client.statuses(head_project, sha)
-      status = statuses.first
-      ref_state = status ? status['state'] : 'no status'
+      if verbose or format
+        checks = ci_checks(head_project, sha)
+        ref_state = ci_combined_state(checks)
+        if format
+          checks.each { |check| $stdout.print format_ci_check(check, format) }
+        else
+          colorize = $stdout.tty? && !ENV['NO_COLOR']
+          name_width = checks.map { |check| check[:name].length }.max
+          durations = checks.map { |check| check[:duration] ? "(#{format_duration(check[:duration])})" : '' }
+          duration_width = durations.map { |duration| duration.length }.max
+          checks.each_with_index do |check, i|
+            glyph = CI_GLYPHS[check[:state]]
+            glyph = "\e[#{CI_COLORS[check[:state]]}m#{glyph}\e[m" if colorize
+            columns = [glyph, check[:name].ljust(name_width)]
+            columns << durations[i].ljust(duration_width) if duration_width > 0
+            columns << check[:url].to_s
+            $stdout.puts columns.join('  ').rstrip
+          end
+          $stdout.puts ref_state
+        end
+      else
+        statuses = api_client.statuses(head_project, sha)
+        status = statuses.first
+        ref_state = status ? status['state'] : 'no status'
+        $stdout.puts ref_state
+      end
 
       exit_code = case ref_state
         when 'success'          then 0
         when 'failure', 'error' then 1
-        when 'pending'          then 2
+        when 'pending'          then pending_ok ? 0 : 2
         else 3
         end
-
-      $stdout.puts ref_state
       exit exit_code
     end
 
+    # $ hub sync
+    # > git fetch --prune --quiet --all
+    # (fast-forwards local branches that are behind their upstream and
+    # deletes those whose upstream is gone after their pull request got merged)
+    #
+    # $ hub sync --dry-run
+    def sync(args)
+      dry_run = args.delete('--dry-run')
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+
+      # a dry run leaves remote-tracking branches of deleted branches alone,
+      # so only upstreams that were already pruned show up as gone
+      fetch_flags = dry_run ? %w[--quiet --all] : %w[--prune --quiet --all]
+      exit 1 unless git_system('fetch', *fetch_flags)
+
+      current = current_branch && current_branch.short_name
+      format = '--format=%(refname:short) %(upstream) %(upstream:track)'
+      branches = local_repo.git_command(['for-each-ref', format, 'refs/heads']).to_s.split("\n")
+
+      branches.each do |line|
+        name, upstream, track = line.split(' ', 3)
+        # branches that were never pushed anywhere are left alone
+        next unless upstream =~ %r{^refs/remotes/([^/]+)/(.+)$}
+        remote_name, remote_branch = $1, $2
+        upstream_name = "#{remote_name}/#{remote_branch}"
+        track = track.to_s
+
+        if track.include?('gone')
+          sync_gone_branch(project, name, remote_name, remote_branch, name == current, dry_run)
+        elsif track.include?('ahead') && track.include?('behind')
+          warn "Warning: #{name} has diverged from #{upstream_name}; not updating it"
+        elsif track.include?('behind')
+          if dry_run
+            puts "Would fast-forward #{name} to #{upstream_name}"
+          elsif name == current ? git_system('merge', '--ff-only', '--quiet', upstream_name) :
+                                  git_system('update-ref', "refs/heads/#{name}", upstream)
+            puts "Fast-forwarded #{name} to #{upstream_name}"
+          else
+            warn "Warning: couldn't fast-forward #{name} to #{upstream_name}"
+          end
+        end
+      end
+
+      exit
+    rescue GitHubAPI::Exceptions
+      display_api_exception("checking pull requests", $!.response)
+      exit 1
+    end
+
+    # $ hub api user
+    # $ hub api -X PATCH repos/mislav/dotfiles -F has_wiki=false
+    # $ hub api --paginate -t repos/mislav/dotfiles/issues
+    # $ hub api graphql -f query=@query.graphql -F first=10
+    def api(args)
+      args.shift
+      usage = "Usage: hub api [-X <METHOD>] [-f|-F <KEY>=<VALUE>] [-H <HEADER>] [--paginate] [-t] <PATH>"
+      method = path = nil
+      fields = {}
+      headers = {}
+      paginate = flat = false
+
+      until args.empty?
+        case arg = args.shift
+        when '-X', '--method'
+          method = args.shift or abort usage
+          method = method.upcase
+        when '-f', '--raw-field', '-F', '--field'
+          field = args.shift or abort usage
+          key, value = field.split('=', 2)
+          abort "Error: invalid field #{field.inspect} (use <KEY>=<VALUE>)" if value.nil? or key.empty?
+          fields[key] = api_field_value(value, %w[-F --field].include?(arg))
+        when '-H', '--header'
+          header = args.shift or abort usage
+          name, value = header.split(/:\s*/, 2)
+          abort "Error: invalid header #{header.inspect} (use \"<NAME>: <VALUE>\")" if value.nil?
+          headers[name] = value
+        when '--paginate'
+          paginate = true
+        when '-t', '--flat'
+          flat = true
+        else
+          abort usage if path or arg.index('-') == 0
+          path = arg
+        end
+      end
+      abort usage unless path
+
+      graphql = 'graphql' == path
+      if graphql
+        # every field but the query is a variable of the query
+        params = { 'query' => fields.delete('query') }
+        params['variables'] = fields unless fields.empty?
+        method ||= 'POST'
+      else
+        params = fields unless fields.empty?
+        method ||= params ? 'POST' : 'GET'
+      end
+      unless GitHubAPI::REQUEST_METHODS.include?(method)
+        abort "Error: invalid HTTP method #{method.inspect}"
+      end
+
+      project = local_repo(false) && local_repo.main_project
+      host = project ? project.host : Context::LocalRepo.default_host
+
+      pages = []
+      url = path
+      while url
+        res = api_client.api_request(host, method, url, params, headers)
+        failed = !res.success? || (graphql && res.data? && res.data['errors'])
+        if failed
+          $stdout.puts res.body unless res.body.to_s.empty?
+          exit 1
+        end
+        pages << res
+        # the "next" link already includes the query string
+        url, params = (paginate && res.next_page_url), nil
+      end
+
+      if pages.size > 1 and pages.all? { |page| page.data? and Array === page.data }
+        data = pages.inject([]) { |all, page| all.concat page.data }
+        flat ? print_flat_json(data) : $stdout.puts(JSON.generate(data))
+      else
+        pages.each do |page|
+          if flat and page.data? then print_flat_json(page.data)
+          elsif !page.body.to_s.empty? then $stdout.puts page.body
+          end
+        end
+      end
+      exit
+    end
+
     # $ hub pull-request
-    # $ hub pull-request "My humble contribution"
+    # $ hub pull-request -m "My humble contribution" -m "Details follow."
+    # $ hub pull-request -F message.txt
+    # $ hub pull-request -m "Open me in a browser" -o
+    # $ hub pull-request -p -m "Push the current branch first"
     # $ hub pull-request -i 92
     # $ hub pull-request https://github.com/rtomayko/tilt/issues/92
     def pull_request(args)
       args.shift
       options = { }
-      force = explicit_owner = false
+      messages = []
+      file_message = nil
+      force = explicit_owner = open_url = copy_url = push = false
       base_project = local_repo.main_project
       head_project = local_repo.current_project
 
@@ -121,31 +309,42 @@ module Hub
         abort "Aborted: the origin remote doesn't point to a GitHub repository."
       end
 
+      # "branch", "owner:branch", "owner/repo:branch", "owner:" or "owner"
       from_github_ref = lambda do |ref, context_project|
         if ref.index(':')
           owner, ref = ref.split(':', 2)
           project = github_project(context_project.name, owner)
         end
-        [project || context_project, ref]
+        [project || context_project, ref.empty? ? nil : ref]
       end
 
       while arg = args.shift
         case arg
         when '-f'
           force = true
+        when '-p', '--push'
+          push = true
+        when '-o', '--browse'
+          open_url = true
+        when '-c', '--copy'
+          copy_url = true
         when '-F', '--file'
-          file = args.shift
-          text = file == '-' ? $stdin.read : File.read(file)
-          options[:title], options[:body] = read_msg(text)
+          file_message = read_file_arg(args.shift)
         when '-m', '--message'
-          text = args.shift
-          options[:title], options[:body] = read_msg(text)
-        when '-b'
+          messages << args.shift
+        when '-b', '--base'
           base_project, options[:base] = from_github_ref.call(args.shift, base_project)
-        when '-h'
+        when '-h', '--head'
           head = args.shift
+          # a bare remote name or owner of a remote's project is "owner:"
+          if !head.index(':') and remote = remotes.find { |r|
+              r.project and (r.name == head or r.project.owner == head) }
+            head = "#{remote.project.owner}:"
+          end
           explicit_owner = !!head.index(':')
           head_project, options[:head] = from_github_ref.call(head, head_project)
+          # "owner:" stands for the current branch in owner's fork
+          options[:head] ||= current_branch.short_name
         when '-i'
           options[:issue] = args.shift
         else
@@ -162,6 +361,29 @@ module Hub
         end
       end
 
+      # like `git commit`, the first `-m` is the title and the rest become
+      # paragraphs of the body; `-m` takes precedence over `-F`
+      if messages.any? or file_message
+        message = messages.any? ? messages.join("\n\n") : file_message
+        options[:title], options[:body] = read_msg(message)
+        abort "Aborting due to empty pull request title" unless options[:title]
+      end
+
+      if push
+        abort "Aborted: `-p` pushes the current branch and can't be used with `-h`" if options[:head]
+        # prefer own fork over the project that the pull request is sent to
+        push_remote = remotes.find { |remote|
+          project = remote.project and project.owner == github_user(project.host)
+        } || origin_remote
+        head_project = push_remote.project
+        options[:head] = current_branch.short_name
+        explicit_owner = true
+
+        unless args.noop? or git_system('push', '-u', push_remote.to_s, options[:head])
+          abort "Aborted: could not push #{options[:head]} to #{push_remote}"
+        end
+      end
+
       options[:project] = base_project
       options[:base] ||= master_branch.short_name
 
@@ -185,7 +407,9 @@ module Hub
       end
 
       remote_branch = "#{head_project.remote}/#{options[:head]}"
-      options[:head] = "#{head_project.owner}:#{options[:head]}"
+      head_label = "#{head_project.owner}:#{options[:head]}"
+      # the head needs an owner prefix only when it's in another fork than base
+      options[:head] = head_label unless head_project.owner == base_project.owner
 
       if !force and tracked_branch and local_commits = rev_list(remote_branch, nil)
         $stderr.puts "Aborted: #{local_commits.split("\n").size} commits are not yet pushed to #{remote_branch}"
@@ -194,7 +418,7 @@ module Hub
       end
 
       if args.noop?
-        puts "Would request a pull to #{base_project.owner}:#{options[:base]} from #{options[:head]}"
+        puts "Would request a pull to #{base_project.owner}:#{options[:base]} from #{head_label}"
         exit
       end
 
@@ -220,7 +444,7 @@ module Hub
           initial_message ||= default_message
           msg.puts initial_message if initial_message
           msg.puts ""
-          msg.puts "# Requesting a pull to #{base_project.owner}:#{options[:base]} from #{options[:head]}"
+          msg.puts "# Requesting a pull to #{base_project.owner}:#{options[:base]} from #{head_label}"
           msg.puts "#"
           msg.puts "# Write a message for this pull request. The first block"
           msg.puts "# of text is the title and the rest is description."
@@ -228,9 +452,18 @@ module Hub
       end
 
       pull = api_client.create_pullrequest(options)
-
-      args.executable = 'echo'
-      args.replace [pull['html_url']]
+      url = pull['html_url']
+
+      copy_to_clipboard(url) if copy_url
+      if open_url
+        args.executable = browser_launcher
+        args.replace [url]
+      elsif copy_url
+        args.skip!
+      else
+        args.executable = 'echo'
+        args.replace [url]
+      end
     rescue GitHubAPI::Exceptions
       response = $!.response
       display_api_exception("creating pull request", response)
@@ -243,20 +476,152 @@ module Hub
       delete_editmsg
     end
 
+    # $ hub pr list
+    # $ hub pr list --state closed --base master -L 100
+    # $ hub pr list --format '%I %U'
+    # $ hub pr checkout 73
+    # $ hub pr checkout https://github.com/defunkt/hub/pull/73 [<BRANCH>]
+    # $ hub pr merge --squash -d
+    # $ hub pr merge 73 -m "Add feature"
+    # $ hub pr show 73 --comments
+    # $ hub pr show --web
+    def pr(args)
+      case args[1]
+      when 'list'     then pr_list(args)
+      when 'checkout' then pr_checkout(args)
+      when 'merge'    then pr_merge(args)
+      when 'show'     then pr_show(args)
+      else
+        abort "Usage: hub pr list [-s <STATE>] [-b <BASE>] [-h <HEAD>] [-o <SORT>] [-L <LIMIT>] [-f <FORMAT>]\n" +
+              "   or: hub pr checkout <PULLREQ-NUMBER|PULLREQ-URL|BRANCH> [<NEW-BRANCH>]\n" +
+              "   or: hub pr merge [<PULLREQ-NUMBER|PULLREQ-URL|BRANCH>] [--merge|--squash|--rebase] [-m <MESSAGE>] [-d] [--admin]\n" +
+              "   or: hub pr show [<PULLREQ-NUMBER|PULLREQ-URL|BRANCH>] [-w] [-c] [-f <FORMAT>]"
+      end
+    rescue GitHubAPI::Exceptions
+      response = $!.response
+      display_api_exception("#{args[1] == 'merge' ? 'merging' : 'fetching'} pull request", response)
+      # e.g. "Pull Request is not mergeable" or "Head branch was modified"
+      warn response.data['message'] if [405, 409].include?(response.status) and response.data?
+      exit 1
+    end
+
+    # $ hub issue
+    # $ hub issue -l bug,ui -a mislav --sort updated
+    # $ hub issue list --format '%I %U'
+    # $ hub issue show 42 --comments
+    # $ hub issue close #42 -m "Fixed in 1.2"
+    # $ hub issue reopen https://github.com/defunkt/hub/issues/42
+    # $ hub issue comment 42 -F notes.txt
+    # $ hub issue create -m "Crash on startup" -l bug -a mislav -M v1.2
+    def issue(args)
+      case args[1]
+      when nil, 'list', /^-/ then issue_list(args)
+      when 'create'  then return issue_create(args)
+      when 'show'    then issue_show(args)
+      when 'close'   then issue_close(args)
+      when 'reopen'  then issue_reopen(args)
+      when 'comment' then issue_comment(args)
+      else
+        abort "Usage: hub issue [list] [-s <STATE>] [-l <LABELS>] [-a <USER>] [-M <MILESTONE>] [-c <USER>] [-@ <USER>]\n" +
+              "                  [--since <DATE>] [-o <SORT>] [-L <LIMIT>] [--include-pulls] [-f <FORMAT>]\n" +
+              "   or: hub issue show <ISSUE> [-c]\n" +
+              "   or: hub issue close <ISSUE> [-m <MESSAGE>|-F <FILE>]\n" +
+              "   or: hub issue reopen <ISSUE>\n" +
+              "   or: hub issue comment <ISSUE> (-m <MESSAGE>|-F <FILE>)\n" +
+              "   or: hub issue create [-m <MESSAGE>|-F <FILE>] [-l <LABELS>] [-a <USERS>] [-M <MILESTONE>] [-o]"
+      end
+      args.skip!
+    rescue GitHubAPI::Exceptions
+      action = case args[1]
+        when 'create' then "creating issue"
+        when 'close', 'reopen', 'comment' then "updating issue"
+        else "fetching issues"
+        end
+      display_api_exception(action, $!.response)
+      exit 1
+    end
+
+    # $ hub label
+    # $ hub label create bug --color ff0000 --description "Something is broken"
+    # $ hub label rename bug defect
+    # $ hub label delete wontfix --yes
+    # $ hub label --copy-from github/hub --force
+    def label(args)
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+
+      case args[1]
+      when nil, 'list'   then label_list(project)
+      when 'create'      then label_create(project, args[2..-1])
+      when 'delete'      then label_delete(project, args[2..-1])
+      when 'rename'      then label_rename(project, args[2..-1])
+      when '--copy-from' then label_copy(project, args[2..-1])
+      else
+        abort "Usage: hub label [list]\n" +
+              "   or: hub label create <NAME> [--color <HEX>] [--description <TEXT>]\n" +
+              "   or: hub label delete <NAME> [--yes]\n" +
+              "   or: hub label rename <OLD> <NEW>\n" +
+              "   or: hub label --copy-from <OWNER/REPO> [--force]"
+      end
+      args.skip!
+    rescue GitHubAPI::Exceptions
+      action = [nil, 'list'].include?(args[1]) ? "fetching labels" : "updating labels"
+      display_api_exception(action, $!.response)
+      exit 1
+    end
+
+    # $ hub release
+    # $ hub release --include-drafts --exclude-prereleases -L 5
+    # $ hub release --format '%T %pI'
+    # $ hub release show v1.2.0
+    # $ hub release show v1.2.0 --web
+    # $ hub release create v1.2.0 -m "v1.2.0" -a hub.tgz#Linux -a hub.zip#Windows
+    # $ hub release create v1.3.0-rc1 --prerelease --commitish release-1.3 --generate-notes
+    def release(args)
+      case args[1]
+      when nil, 'list', /^-/ then release_list(args)
+      when 'show'     then return release_show(args)
+      when 'create'   then return release_create(args)
+      when 'edit'     then release_edit(args)
+      when 'delete'   then release_delete(args)
+      when 'download' then release_download(args)
+      else
+        abort "Usage: hub release [list] [--include-drafts] [--exclude-prereleases] [-L <LIMIT>] [-f <FORMAT>]\n" +
+              "   or: hub release show <TAG> [-w]\n" +
+              "   or: hub release create <TAG> [-m <MESSAGE>|-F <FILE>] [-a <FILE>[#<LABEL>]] [--draft] [--prerelease]\n" +
+              "                          [--commitish <REF>] [--generate-notes] [-o]\n" +
+              "   or: hub release edit <TAG> [-m <MESSAGE>|-F <FILE>] [-a <FILE>[#<LABEL>]] [--draft|--publish]\n" +
+              "                        [--prerelease|--no-prerelease] [--commitish <REF>]\n" +
+              "   or: hub release delete <TAG> [--with-tag] [--yes]\n" +
+              "   or: hub release download <TAG> [--pattern <GLOB>] [--dir <DIR>] [--clobber]"
+      end
+      args.skip!
+    rescue GitHubAPI::Exceptions
+      action = {'create' => 'creating', 'edit' => 'updating', 'delete' => 'deleting',
+                'download' => 'downloading'}[args[1]] || 'fetching'
+      display_api_exception("#{action} release", $!.response)
+      exit 1
+    end
+
     # $ hub clone rtomayko/tilt
-    # > git clone git://github.com/rtomayko/tilt.
+    # > git clone https://github.com/rtomayko/tilt.git
     #
     # $ hub clone -p kneath/hemingway
     # > git clone git@github.com:kneath/hemingway.git
     #
     # $ hub clone tilt
-    # > git clone git://github.com/YOUR_LOGIN/tilt.
+    # > git clone git@github.com:YOUR_LOGIN/tilt.git
+    #
+    # $ hub clone rtomayko/tilt.wiki
+    # > git clone https://github.com/rtomayko/tilt.wiki.git
     #
-    # $ hub clone -p github
-    # > git clone git@github.com:YOUR_LOGIN/hemingway.git
+    # $ hub clone --depth 1 --recurse-submodules rtomayko/tilt
+    # > git clone --depth 1 --recurse-submodules https://github.com/rtomayko/tilt.git
     def clone(args)
       ssh = args.delete('-p')
-      has_values = /^(--(upload-pack|template|depth|origin|branch|reference|name)|-[ubo])$/
+      has_values = /^(--(upload-pack|template|depth|origin|branch|reference|reference-if-able|name|
+        separate-git-dir|shallow-since|shallow-exclude|filter|jobs|config|server-option)|-[ubocj])$/x
 
       idx = 1
       while idx < args.length
@@ -270,8 +635,11 @@ module Hub
             name, owner = arg, nil
             owner, name = name.split('/', 2) if name.index('/')
             project = github_project(name, owner || github_user)
-            ssh ||= args[0] != 'submodule' && project.owner == github_user(project.host) { }
-            args[idx] = project.git_url(:private => ssh, :https => https_protocol?)
+            args[idx] = if args[0] == 'submodule'
+              project.git_url(:private => ssh, :https => https_protocol?)
+            else
+              clone_url(project, ssh)
+            end
           end
           break
         end
@@ -347,7 +715,7 @@ module Hub
       # $ hub fetch <name>
       elsif remote_name = args.words[1]
         # $ hub fetch <name1>,<name2>,...
-        if remote_name =~ /^\w+(,\w+)+$/
+        if remote_name =~ /^#{OWNER_RE}(,#{OWNER_RE})+$/
           index = args.index(remote_name)
           args.delete(remote_name)
           names = remote_name.split(',')
@@ -367,6 +735,10 @@ module Hub
           if repo_info.success?
             project.repo_data = repo_info.data
             project
+          elsif 404 == repo_info.status
+            warn "Warning: #{project.name_with_owner} doesn't exist on #{project.host}; not adding a remote for #{name}"
+          else
+            repo_info.error!
           end
         end
       }.compact
@@ -376,6 +748,9 @@ module Hub
           args.before ['remote', 'add', project.owner, project.git_url(:https => https_protocol?)]
         end
       end
+    rescue GitHubAPI::Exceptions
+      display_api_exception("fetching repository", $!.response)
+      exit 1
     end
 
     # $ git checkout https://github.com/defunkt/hub/pull/73
@@ -432,11 +807,14 @@ module Hub
     end
 
     # $ git cherry-pick http://github.com/mislav/hub/commit/a319d88#comments
-    # > git remote add -f mislav git://github.com/mislav/hub.git
+    # > git fetch git://github.com/mislav/hub.git +refs/heads/*:refs/remotes/mislav/*
+    # > git remote add mislav git://github.com/mislav/hub.git
     # > git cherry-pick a319d88
     #
     # $ git cherry-pick mislav@a319d88
-    # > git remote add -f mislav git://github.com/mislav/hub.git
+    # $ git cherry-pick mislav:a319d88
+    # > git fetch git://github.com/mislav/hub.git +refs/heads/*:refs/remotes/mislav/*
+    # > git remote add mislav git://github.com/mislav/hub.git
     # > git cherry-pick a319d88
     #
     # $ git cherry-pick mislav@SHA
@@ -448,7 +826,7 @@ module Hub
         if url = resolve_github_url(ref) and url.project_path =~ /^commit\/([a-f0-9]{7,40})/
           sha = $1
           project = url.project
-        elsif ref =~ /^(#{OWNER_RE})@([a-f0-9]{7,40})$/
+        elsif ref =~ /^(#{OWNER_RE})[@:]([a-f0-9]{7,40})$/
           owner, sha = $1, $2
           project = local_repo.main_project.owned_by(owner)
         end
@@ -459,23 +837,44 @@ module Hub
           if remote = project.remote and remotes.include? remote
             args.before ['fetch', remote.to_s]
           else
-            args.before ['remote', 'add', '-f', project.owner, project.git_url(:https => https_protocol?)]
+            # fetch before adding the remote so a failed fetch doesn't leave
+            # a remote behind; later cherry-picks reuse the remote
+            url = project.git_url(:https => https_protocol?)
+            args.before ['fetch', url, "+refs/heads/*:refs/remotes/#{project.owner}/*"]
+            args.before ['remote', 'add', project.owner, url]
           end
         end
       end
     end
 
     # $ hub am https://github.com/defunkt/hub/pull/55
-    # > curl https://github.com/defunkt/hub/pull/55.patch -o /tmp/55.patch
+    # (patch of the pull request is saved to /tmp/55.patch)
     # > git am /tmp/55.patch
+    #
+    # $ hub am https://github.com/davidbalbert/hub/commit/fdb9921
+    # > curl https://github.com/davidbalbert/hub/commit/fdb9921.patch -o /tmp/fdb9921.patch
+    # > git am /tmp/fdb9921.patch
     def am(args)
-      if url = args.find { |a| a =~ %r{^https?://(gist\.)?github\.com/} }
+      if url = args.find { |a| a =~ %r{^https?://gist\.github\.com/} or resolve_github_url(a) }
         idx = args.index(url)
-        gist = $1 == 'gist.'
+        github_url = resolve_github_url(url)
+        gist = github_url.nil?
+
+        case github_url && github_url.project_path
+        when %r{^issues/\d+}
+          abort "Error: #{url} is an issue, not a pull request\n" +
+            "(use the URL of the pull request that has the changes)"
+        when %r{^pull/(\d+)}
+          # works for private repos and Enterprise hosts, unlike curl
+          pull_id = $1
+          patch_file = File.join(tmp_dir, "#{pull_id}.patch")
+          patch = api_client.pullrequest_patch(github_url.project, pull_id)
+          File.open(patch_file, 'w') { |file| file.write patch }
+          return args[idx] = patch_file
+        end
+
         # strip the fragment part of the url
         url = url.sub(/#.+/, '')
-        # strip extra path from "pull/42/files", "pull/42/commits"
-        url = url.sub(%r{(/pull/\d+)/\w*$}, '\1') unless gist
         ext = gist ? '.txt' : '.patch'
         url += ext unless File.extname(url) == ext
         patch_file = File.join(tmp_dir, "#{gist ? 'gist-' : ''}#{File.basename(url)}")
@@ -483,10 +882,13 @@ module Hub
         args.before 'curl', ['-#LA', "hub #{Hub::Version}", url, '-o', patch_file]
         args[idx] = patch_file
       end
+    rescue GitHubAPI::Exceptions
+      display_api_exception("fetching pull request patch", $!.response)
+      exit 1
     end
 
     # $ hub apply https://github.com/defunkt/hub/pull/55
-    # > curl https://github.com/defunkt/hub/pull/55.patch -o /tmp/55.patch
+    # (patch of the pull request is saved to /tmp/55.patch)
     # > git apply /tmp/55.patch
     alias_method :apply, :am
 
@@ -537,6 +939,11 @@ module Hub
     # $ hub create
     # ... create repo on github ...
     # > git remote add -f origin git@github.com:YOUR_USER/CURRENT_REPO.git
+    #
+    # $ hub create -o --remote-name github acme/widgets
+    # ... create repo under the "acme" organization ...
+    # > git remote add -f github git@github.com:acme/widgets.git
+    # > open https://github.com/acme/widgets
     def create(args)
       if !is_repo?
         abort "'create' must be run from inside a git repository"
@@ -546,6 +953,8 @@ module Hub
         options = {}
         options[:private] = true if args.delete('-p')
         new_repo_name = nil
+        remote_name = 'origin'
+        open_url = copy_url = false
 
         until args.empty?
           case arg = args.shift
@@ -553,6 +962,12 @@ module Hub
             options[:description] = args.shift
           when '-h'
             options[:homepage] = args.shift
+          when '--remote-name'
+            remote_name = args.shift or abort "Usage: hub create --remote-name <NAME>"
+          when '-o', '--browse'
+            open_url = true
+          when '-c', '--copy'
+            copy_url = true
           else
             if arg =~ /^[^-]/ and new_repo_name.nil?
               new_repo_name = arg
@@ -565,9 +980,19 @@ module Hub
         new_repo_name ||= repo_name
         new_project = github_project(new_repo_name, owner)
 
+        # refuse to point an existing remote at a different repo
+        existing_remote = local_repo.remote_by_name(remote_name)
+        if existing_remote
+          remote_project = existing_remote.project
+          unless remote_project and remote_project.name_with_owner.downcase == new_project.name_with_owner.downcase
+            abort "Aborted: remote \"#{remote_name}\" already exists and points to #{existing_remote.urls.values.first}\n" +
+              "(use `--remote-name` to add the new repository under another name)"
+          end
+        end
+
         if api_client.repo_exists?(new_project)
           warn "#{new_project.name_with_owner} already exists on #{new_project.host}"
-          action = "set remote origin"
+          action = "existing repository detected"
         else
           action = "created repository"
           unless args.noop?
@@ -578,13 +1003,18 @@ module Hub
 
         url = new_project.git_url(:private => true, :https => https_protocol?)
 
-        if remotes.first != 'origin'
-          args.replace %W"remote add -f origin #{url}"
-        else
+        if existing_remote
           args.replace %W"remote -v"
+        else
+          args.replace %W"remote add -f #{remote_name} #{url}"
         end
 
         args.after 'echo', ["#{action}:", new_project.name_with_owner]
+        copy_to_clipboard(new_project.web_url) if copy_url
+        if open_url
+          launcher = browser_launcher
+          args.after launcher.first, launcher[1..-1] + [new_project.web_url]
+        end
       end
     rescue GitHubAPI::Exceptions
       display_api_exception("creating repository", $!.response)
@@ -594,21 +1024,51 @@ module Hub
     # $ hub push origin,staging cool-feature
     # > git push origin cool-feature
     # > git push staging cool-feature
+    #
+    # $ hub push --continue-on-error origin,staging,qa
+    # (pushes to every remote even if some of them fail, then prints a summary)
     def push(args)
-      return if args[1].nil? || !args[1].index(',')
+      flags, words = [], []
+      rest = args[1..-1]
+      while arg = rest.shift
+        if arg.index('-') == 0
+          flags << arg
+          flags << rest.shift if PUSH_VALUE_FLAGS.include?(arg) && rest.any?
+        else
+          words << arg
+        end
+      end
 
-      refs    = args.words[2..-1]
-      remotes = args[1].split(',')
-      args[1] = remotes.shift
+      target = words.shift
+      return if target.nil? || !target.index(',')
 
-      if refs.empty?
-        # add current branch as explicit ref when there are no refs specified
-        refs = [current_branch.short_name]
-        args.concat refs
+      continue_on_error = flags.delete('--continue-on-error')
+      names = target.split(',')
+      unknown = names - remotes.map { |remote| remote.name }
+      unless unknown.empty?
+        abort "Aborted: no such remote: #{unknown.join(', ')}"
       end
 
-      remotes.each do |name|
-        args.after ['push', name, *refs]
+      # add current branch as explicit ref when there are no refs specified
+      refs = words.empty? ? [current_branch.short_name] : words
+      pushes = names.map { |name| ['push', *flags] + [name, *refs] }
+
+      if continue_on_error && !args.noop?
+        failed = []
+        pushes.zip(names).each do |push, name|
+          args.before { failed << name unless system(*args.to_exec(push)) }
+        end
+        args.after {
+          pushed = names - failed
+          $stderr.puts "Pushed to: #{pushed.join(', ')}" if pushed.any?
+          $stderr.puts "Failed to push to: #{failed.join(', ')}" if failed.any?
+          exit(failed.empty? ? 0 : 1)
+        }
+        # the pushes and the summary are all that runs
+        args.clear
+      else
+        args.replace pushes.shift
+        pushes.each { |push| args.after push }
       end
     end
 
@@ -626,13 +1086,26 @@ module Hub
     #
     # $ hub browse github-services wiki
     # > open https://github.com/YOUR_LOGIN/github-services/wiki
+    #
+    # $ hub browse --issues
+    # > open https://github.com/CURRENT_REPO/issues
+    #
+    # $ hub browse lib/hub.rb:30
+    # > open https://github.com/CURRENT_REPO/blob/CURRENT_BRANCH/lib/hub.rb#L30
+    #
+    # $ hub browse --upstream -- pulls
+    # > open https://github.com/PARENT_REPO/pulls
     def browse(args)
       args.shift
+      upstream = args.delete('--upstream')
+      target = BROWSE_TARGETS.find { |name| args.delete("--#{name}") }
+
       browse_command(args) do
         dest = args.shift
         dest = nil if dest == '--'
+        file = browse_file(dest) if dest
 
-        if dest
+        if dest and !file
           # $ hub browse pjhyett/github-services
           # $ hub browse github-services
           project = github_project dest
@@ -645,53 +1118,62 @@ module Hub
 
         abort "Usage: hub browse [<USER>/]<REPOSITORY>" unless project
 
+        if upstream
+          project = fork_parent(project)
+          # the current branch likely doesn't exist in the parent repo
+          branch = nil unless file
+        end
+
         require 'cgi'
-        # $ hub browse -- wiki
-        path = case subpage = args.shift
-        when 'commits'
-          "/commits/#{branch_in_url(branch)}"
-        when 'tree', NilClass
-          "/tree/#{branch_in_url(branch)}" if branch and !branch.master?
+        if file
+          # $ hub browse README.md:30
+          path, line, directory = file
+          branch = current_branch && (current_branch.upstream || current_branch) || master_branch
+          path = "/#{directory ? 'tree' : 'blob'}/#{branch_in_url(branch)}/" +
+            path.split('/').map { |part| CGI.escape(part).gsub('+', '%20') }.join('/')
+          path << "#L#{line}" if line
         else
-          "/#{subpage}"
+          # $ hub browse -- wiki
+          path = case subpage = args.shift || target
+          when 'commits'
+            "/commits/#{branch_in_url(branch)}" if branch
+          when 'tree', NilClass
+            "/tree/#{branch_in_url(branch)}" if branch and !branch.master?
+          else
+            "/#{subpage}"
+          end
         end
 
         project.web_url(path)
       end
     end
 
-    # $ hub compare 1.0..fix
+    # $ hub compare 1.0...fix
     # > open https://github.com/CURRENT_REPO/compare/1.0...fix
     # $ hub compare refactor
     # > open https://github.com/CURRENT_REPO/compare/refactor
     # $ hub compare myfork feature
     # > open https://github.com/myfork/REPO/compare/feature
+    # $ hub compare mislav:master...defunkt:feature
+    # > open https://github.com/CURRENT_REPO/compare/mislav:master...defunkt:feature
     # $ hub compare -u 1.0...2.0
     # "https://github.com/CURRENT_REPO/compare/1.0...2.0"
+    # $ hub compare --stat refactor
+    # > prints ahead/behind counts and commits of DEFAULT_BRANCH...refactor
     def compare(args)
       args.shift
-      browse_command(args) do
-        if args.empty?
-          branch = current_branch.upstream
-          if branch and not branch.master?
-            range = branch.short_name
-            project = current_project
-          else
-            abort "Usage: hub compare [USER] [<START>...]<END>"
-          end
-        else
-          sha_or_tag = /((?:#{OWNER_RE}:)?\w[\w.-]+\w)/
-          # replaces two dots with three: "sha1...sha2"
-          range = args.pop.sub(/^#{sha_or_tag}\.\.#{sha_or_tag}$/, '\1...\2')
-          project = if owner = args.pop then github_project(nil, owner)
-                    else current_project
-                    end
-        end
+      if args.delete('--stat')
+        %w[-u --url -c --copy].each { |flag| args.delete(flag) }
+        project, range = compare_range(args)
+        compare_stat(project, range)
+        return args.skip!
+      end
 
+      browse_command(args) do
+        project, range = compare_range(args)
         project.web_url "/compare/#{range}"
       end
     end
-
     # $ hub hub standalone
     # Prints the "standalone" version of hub for an easy, memorable
     # installation sequence:
@@ -800,7 +1282,14 @@ module Hub
 
     def github_user host = nil, &block
       host ||= (local_repo(false) || Context::LocalRepo).default_host
-      api_client.config.username(host, &block)
+      user = api_client.config.username(host, &block)
+      if user.nil? and api_client.config.token_without_username?(host)
+        user = api_client.current_user_login(host)
+      end
+      user
+    rescue GitHubAPI::Exceptions
+      display_api_exception("fetching current user", $!.response)
+      exit 1
     end
 
     def custom_command? cmd
@@ -871,11 +1360,17 @@ Advanced Commands:
 
 GitHub Commands:
    pull-request   Open a pull request on GitHub
+   pr             Work with pull requests on GitHub
+   issue          Work with issues on GitHub
+   label          List and edit labels of a GitHub repository
+   release        List and show releases on GitHub
    fork           Make a fork of a remote repository on GitHub and add as remote
    create         Create this repository on GitHub and add GitHub as origin
    browse         Open a GitHub page in the default browser
    compare        Open a compare page on GitHub
    ci-status      Show the CI status of a commit
+   sync           Update local branches and delete those that were merged
+   api            Make an authenticated request to the GitHub API
 
 See 'git help <command>' for more information on a specific command.
 help
@@ -892,9 +1387,10 @@ help
     #
     # Special: `--version`, `--help` are replaced with "version" and "help".
     # Ignored: `--exec-path`, `--html-path` are kept in args list untouched.
+    # Hub-only: `--noop` and `--host` are never passed on to git.
     def slurp_global_flags(args)
-      flags = %w[ --noop -c -p --paginate --no-pager --no-replace-objects --bare --version --help ]
-      flags2 = %w[ --exec-path= --git-dir= --work-tree= ]
+      flags = %w[ --noop --host -c -p --paginate --no-pager --no-replace-objects --bare --version --help ]
+      flags2 = %w[ --host= --exec-path= --git-dir= --work-tree= ]
 
       # flags that should be present in subcommands, too
       globals = []
@@ -906,6 +1402,10 @@ help
         case flag
         when '--noop'
           args.noop!
+        when '--host', /^--host=/
+          host = flag == '--host' ? args.shift : flag.sub('--host=', '')
+          abort "Error: the `--host` flag requires a host name" if host.to_s.empty?
+          Context::LocalRepo.host_override = host
         when '--version', '--help'
           args.unshift flag.sub('--', '')
         when '-c'
@@ -931,144 +1431,1537 @@ help
     # Handles common functionality of browser commands like `browse`
     # and `compare`. Yields a block that returns params for `github_url`.
     def browse_command(args)
-      url_only = args.delete('-u')
+      url_only = args.delete('-u') || args.delete('--url')
+      copy = args.delete('-c') || args.delete('--copy')
       warn "Warning: the `-p` flag has no effect anymore" if args.delete('-p')
       url = yield
 
-      args.executable = url_only ? 'echo' : browser_launcher
-      args.push url
+      copy_to_clipboard(url) if copy
+      if copy and !url_only
+        args.skip!
+      else
+        args.executable = url_only ? 'echo' : browser_launcher
+        args.push url
+      end
     end
 
-    # Returns the terminal-formatted manpage, ready to be printed to
-    # the screen.
-    def hub_manpage
-      abort "** Can't find groff(1)" unless command?('groff')
-
-      require 'open3'
-      out = nil
-      Open3.popen3(groff_command) do |stdin, stdout, _|
-        stdin.puts hub_raw_manpage
-        stdin.close
-        out = stdout.read.strip
+    # URL for cloning a project: over SSH if the user can push to it or it's
+    # on an Enterprise host, and over HTTPS otherwise. The "hub.protocol" git
+    # config can force "ssh", "https" or "git".
+    def clone_url(project, ssh = false)
+      protocol = git_config('hub.protocol')
+      protocol = 'https' if http_clone?
+      ssh ||= 'ssh' == protocol || (protocol.nil? && (project.private? || can_push_to?(project)))
+      if ssh then project.git_url(:private => true)
+      elsif 'git' == protocol then project.git_url
+      else project.git_url(:https => true)
       end
-      out
     end
 
-    # The groff command complete with crazy arguments we need to run
-    # in order to turn our raw roff (manpage markup) into something
-    # readable on the terminal.
-    def groff_command
-      cols = terminal_width
-      "groff -Wall -mtty-char -mandoc -Tascii -rLL=#{cols}n -rLT=#{cols}n"
+    # Whether the user owns a project or can push to it as a collaborator.
+    # The API is only asked if the user is already authenticated to its host.
+    def can_push_to?(project)
+      return false unless user = github_user(project.host) { }
+      return true if project.owner.downcase == user.downcase
+      return false unless api_client.config.oauth_token?(project.host, user)
+      # the wiki of a repo is writable by whoever can push to the repo
+      repo = project.dup
+      repo.name = repo.name.sub(/\.wiki$/, '')
+      api_client.can_push?(repo)
+    rescue GitHubAPI::Exceptions
+      false
     end
 
-    # Returns the raw hub manpage. If we're not running in standalone
-    # mode, it's a file sitting at the root under the `man`
-    # directory.
-    #
-    # If we are running in standalone mode the manpage will be
-    # included after the __END__ of the file so we can grab it using
-    # DATA.
-    def hub_raw_manpage
-      if File.exists? file = File.dirname(__FILE__) + '/../../man/hub.1'
-        File.read(file)
+    # The project and range that `compare` was asked about. Without arguments,
+    # that's the branch that the current branch is pushed to.
+    def compare_range(args)
+      if args.empty?
+        branch = current_branch && current_branch.upstream
+        if branch and not branch.master?
+          [current_project, branch.short_name]
+        else
+          abort "Usage: hub compare [USER] [<START>...]<END>"
+        end
       else
-        DATA.read
+        range = args.pop
+        project = if owner = args.pop then github_project(nil, owner)
+                  else current_project
+                  end
+        [project, range]
       end
     end
 
-    # All calls to `puts` in after hooks or commands are paged,
-    # git-style.
-    def puts(*args)
-      page_stdout
-      super
+    # Prints how far apart the ends of a range are and the commits in it.
+    # A bare branch name is compared to the repo's default branch as GitHub
+    # knows it, which might not be "master".
+    def compare_stat(project, range)
+      unless range.include?('..')
+        range = "#{api_client.default_branch(project)}...#{range}"
+      end
+      comparison = api_client.compare(project, range)
+
+      puts "#{range}: #{comparison['ahead_by']} ahead, #{comparison['behind_by']} behind"
+      commits = comparison['commits'].to_a
+      puts "" unless commits.empty?
+      commits.each do |commit|
+        puts "#{commit['sha'][0, 7]} #{commit['commit']['message'].split("\n").first}"
+      end
+      if comparison['total_commits'].to_i > commits.size
+        puts "... and #{comparison['total_commits'].to_i - commits.size} more"
+      end
+    rescue GitHubAPI::Exceptions
+      display_api_exception("comparing commits", $!.response)
+      exit 1
     end
 
-    # http://nex-3.com/posts/73-git-style-automatic-paging-in-ruby
-    def page_stdout
-      return if not $stdout.tty? or windows?
+    # Recognizes a "path/to/file:LINE" argument of `browse` as a file or
+    # directory in the work tree. Returns its path relative to the root of
+    # the repo, the line number if given, and whether it's a directory.
+    def browse_file(arg)
+      return unless arg =~ /\A(.+?)(?::(\d+))?\z/
+      path, line = $1, $2
+      return unless File.exist?(path) and local_repo(false)
+
+      require 'pathname'
+      prefix = git_command('rev-parse --show-prefix').to_s
+      [Pathname.new(File.join(prefix, path)).cleanpath.to_s, line, File.directory?(path)]
+    end
 
-      read, write = IO.pipe
+    # The project that a fork was made from: the one the "upstream" remote
+    # points to, or else the parent repo according to GitHub.
+    def fork_parent(project)
+      if remote = local_repo(false) && local_repo.remote_by_name('upstream') and remote.project
+        return remote.project
+      end
+      res = api_client.repo_info(project)
+      res.error! unless res.success?
+      unless parent = res.data['parent']
+        abort "Aborted: #{project.name_with_owner} is not a fork"
+      end
+      github_project(parent['full_name'])
+    rescue GitHubAPI::Exceptions
+      display_api_exception("fetching repository", $!.response)
+      exit 1
+    end
 
-      if Kernel.fork
-        # Parent process, become pager
-        $stdin.reopen(read)
-        read.close
-        write.close
+    # Runs a git command in the foreground and returns whether it succeeded.
+    def git_system(*args)
+      system(*(Array(git_reader.executable) + args))
+    end
 
-        # Don't page if the input is short enough
-        ENV['LESS'] = 'FSRX'
+    def copy_to_clipboard(text)
+      IO.popen(clipboard_command.join(' '), 'w') { |io| io.print text }
+    end
 
-        # Wait until we have input before we start the pager
-        Kernel.select [STDIN]
+    # Gathers the latest status of each context and all check runs of a
+    # commit as Hashes with :name, :state ("success", "failure", "error" or
+    # "pending"), :url and :duration in seconds, when it's known.
+    def ci_checks(project, sha)
+      require 'time'
+      checks = api_client.combined_status(project, sha)['statuses'].to_a.map { |status|
+        { :name => status['context'], :state => status['state'], :url => status['target_url'] }
+      }
+      api_client.check_runs(project, sha).each do |run|
+        state = if 'completed' != run['status'] then 'pending'
+          elsif %w[success neutral skipped].include?(run['conclusion']) then 'success'
+          else 'failure'
+          end
+        if run['started_at'] and run['completed_at']
+          duration = (Time.iso8601(run['completed_at']) - Time.iso8601(run['started_at'])).to_i
+        end
+        checks << { :name => run['name'], :state => state,
+                    :url => run['details_url'] || run['html_url'], :duration => duration }
+      end
+      checks.sort_by { |check| check[:name].downcase }
+    end
 
-        pager = ENV['GIT_PAGER'] ||
-          `git config --get-all core.pager`.split("\n").first ||
-          ENV['PAGER'] ||
-          'less -isr'
+    # The state of a commit's checks taken together: failing if any of them
+    # fails, pending while any is still running.
+    def ci_combined_state(checks)
+      states = checks.map { |check| check[:state] }
+      if states.empty? then 'no status'
+      elsif states.include?('error') then 'error'
+      elsif states.include?('failure') then 'failure'
+      elsif states.include?('pending') then 'pending'
+      else 'success'
+      end
+    end
 
-        pager = 'cat' if pager.empty?
+    # Expands placeholders in a `ci-status` format string:
+    #
+    #   %S  - state
+    #   %t  - name of the status context or check run
+    #   %U  - URL of the details
+    #   %d  - duration, e.g. "1m23s", if known
+    #   %n  - newline
+    #   %%  - literal "%"
+    def format_ci_check(check, format)
+      format.gsub(/%([StUdn%])/) do
+        case $1
+        when 'S' then check[:state]
+        when 't' then check[:name]
+        when 'U' then check[:url].to_s
+        when 'd' then check[:duration] ? format_duration(check[:duration]) : ''
+        when 'n' then "\n"
+        when '%' then '%'
+        end
+      end
+    end
 
-        exec pager rescue exec "/bin/sh", "-c", pager
-      else
-        # Child process
-        $stdout.reopen(write)
-        $stderr.reopen(write) if $stderr.tty?
-        read.close
-        write.close
+    # Formats seconds like "45s", "1m23s" or "2h5m".
+    def format_duration(seconds)
+      if seconds < 60 then "#{seconds}s"
+      elsif seconds < 3600 then "#{seconds / 60}m#{seconds % 60}s"
+      else "#{seconds / 3600}h#{seconds % 3600 / 60}m"
       end
-    rescue NotImplementedError
-      # fork might not available, such as in JRuby
     end
 
-    def pullrequest_editmsg(changes)
-      message_file = pullrequest_editmsg_file
+    # Deletes a local branch whose upstream was deleted from the remote, but
+    # only after finding the merged pull request that came from it and
+    # making sure the branch points to the head of that pull request. The
+    # current branch is switched to the default branch first.
+    def sync_gone_branch(project, name, remote_name, remote_branch, current, dry_run)
+      remote = remotes.find { |r| r.name == remote_name }
+      unless remote and head_project = remote.project
+        warn "Warning: #{name} was deleted from #{remote_name}; not deleting it"
+        return
+      end
 
-      if valid_editmsg_file?(message_file)
-        title, body = read_editmsg(message_file)
-        previous_message = [title, body].compact.join("\n\n") if title
+      head = "#{head_project.owner}:#{remote_branch}"
+      pulls = api_client.pullrequests(project, :head => head, :state => 'closed')
+      unless pull = pulls.find { |pr| pr['merged_at'] }
+        warn "Warning: #{name} was deleted from #{remote_name} but its pull request isn't merged; not deleting it"
+        return
       end
 
-      File.open(message_file, 'w') { |msg|
-        yield msg, previous_message
-        if changes
-          msg.puts "#\n# Changes:\n#"
-          msg.puts changes.gsub(/^/, '# ').gsub(/ +$/, '')
-        end
-      }
+      # commits made locally after the merge would be lost
+      local_sha = local_repo.git_command("rev-parse -q --verify refs/heads/#{name}")
+      unless pull['head'] and local_sha == pull['head']['sha']
+        warn "Warning: #{name} has commits that weren't in pull request ##{pull['number']}; not deleting it"
+        return
+      end
 
-      edit_cmd = Array(git_editor).dup
-      edit_cmd << '-c' << 'set ft=gitcommit tw=0 wrap lbr' if edit_cmd[0] =~ /^[mg]?vim$/
-      edit_cmd << message_file
-      system(*edit_cmd)
+      if dry_run
+        puts "Would delete #{name} (pull request ##{pull['number']} was merged)"
+        return
+      end
 
-      unless $?.success?
-        # writing was cancelled, or the editor never opened in the first place
-        delete_editmsg(message_file)
-        abort "error using text editor for pull request message"
+      if current
+        default = api_client.default_branch(project)
+        unless default != name and git_system('checkout', '--quiet', default)
+          warn "Warning: couldn't switch away from #{name}; not deleting it"
+          return
+        end
       end
 
-      title, body = read_editmsg(message_file)
-      abort "Aborting due to empty pull request title" unless title
-      [title, body]
+      if git_system('branch', '--quiet', '-D', name)
+        puts "Deleted #{name} (pull request ##{pull['number']} was merged)"
+      else
+        warn "Warning: couldn't delete #{name}"
+      end
     end
 
-    # This unfortunate hack is because older versions of hub never cleaned up
-    # the pullrequest_editmsg_file, which newer hub would pick up and
-    # misinterpret as a message which should be reused after a failed PR.
-    def valid_editmsg_file?(message_file)
+    # The value of a `hub api` field. "@FILE" stands for the contents of
+    # FILE, or of standard input for "@-". Typed fields (`-F`) turn "true",
+    # "false", "null" and integers into their JSON counterparts.
+    def api_field_value(value, typed)
+      if value.index('@') == 0
+        read_file_arg(value[1..-1])
+      elsif !typed then value
+      elsif 'true' == value then true
+      elsif 'false' == value then false
+      elsif 'null' == value then nil
+      elsif value =~ /\A-?\d+\z/ then value.to_i
+      else value
+      end
+    end
+
+    # Prints JSON data as lines of "KEY<TAB>VALUE", where KEY is the path to
+    # a value such as ".[0].user.login", for parsing in shell scripts.
+    def print_flat_json(data, prefix = '')
+      case data
+      when Hash
+        data.keys.sort.each { |key| print_flat_json(data[key], "#{prefix}.#{key}") }
+      when Array
+        data.each_with_index { |item, i| print_flat_json(item, "#{prefix}.[#{i}]") }
+      else
+        $stdout.puts "#{prefix.empty? ? '.' : prefix}\t#{data.nil? ? 'null' : data}"
+      end
+    end
+
+    # Finds the project and data of a pull request given as accepted by
+    # GitHubAPI#resolve_pullrequest, which is looked up in the current repo
+    # unless it's a URL. Without arg, finds the open pull request whose head
+    # is the current branch.
+    def resolve_pullrequest(arg)
+      if arg.nil?
+        unless branch = current_branch
+          abort "Aborted: not currently on any branch."
+        end
+        project = pullrequest_base_project
+        upstream = branch.upstream
+        arg = if upstream and upstream.remote? and head_project = local_repo.upstream_project
+          "#{head_project.owner}:#{upstream.short_name}"
+        else
+          "#{project.owner}:#{branch.short_name}"
+        end
+      elsif url = resolve_github_url(arg) and url.project_path =~ /^pull\/\d+/
+        project = url.project
+      elsif arg =~ %r{^https?://}
+        abort "Error: #{arg} is not a pull request number or URL"
+      else
+        project = pullrequest_base_project
+      end
+      [project, api_client.resolve_pullrequest(project, arg)]
+    end
+
+    def pullrequest_base_project
+      local_repo.main_project or
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+    end
+
+    # Lists pull requests of the current repo, one per line. Output is only
+    # colored when it goes to a terminal and no custom format is given.
+    def pr_list(args)
+      filters = {}
+      limit = 30
+      format = nil
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '-s', '--state'  then filters[:state] = flags.shift
+        when '-b', '--base'   then filters[:base] = flags.shift
+        when '-h', '--head'   then filters[:head] = flags.shift
+        when '-o', '--sort'   then filters[:sort] = flags.shift
+        when '-L', '--limit'  then limit = flags.shift.to_i
+        when '-f', '--format' then format = flags.shift
+        else
+          abort "invalid argument: #{arg}"
+        end
+      end
+
+      if filters[:state] and !PULLREQ_STATES.include?(filters[:state])
+        abort "invalid state: #{filters[:state]} (use one of: #{PULLREQ_STATES.join(', ')})"
+      end
+      if filters[:sort] and !PULLREQ_SORTS.include?(filters[:sort])
+        abort "invalid sort: #{filters[:sort]} (use one of: #{PULLREQ_SORTS.join(', ')})"
+      end
+      abort "invalid limit: must be a positive number" unless limit > 0
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+      # the API only filters by head given as "owner:branch"
+      if filters[:head] and !filters[:head].index(':')
+        filters[:head] = "#{project.owner}:#{filters[:head]}"
+      end
+
+      if format
+        api_client.pullrequests(project, filters, limit).each do |pull|
+          puts format_pullrequest(pull, format)
+        end
+      else
+        colorize = $stdout.tty?
+        api_client.pullrequests(project, filters, limit).each do |pull|
+          # labels column is left out when there are none
+          puts format_pullrequest(pull, "%I\t%t\t%au\t%H -> %B\t%L", colorize).sub(/\t\z/, '')
+        end
+      end
+      args.skip!
+    end
+
+    # Expands placeholders in a `pr list` format string:
+    #
+    #   %I  - number
+    #   %t  - title
+    #   %au - login of the author
+    #   %H  - head branch, as "owner:branch" if it's in a fork
+    #   %B  - base branch
+    #   %L  - comma-separated label names
+    #   %S  - state: "open", "draft", "closed" or "merged"
+    #   %b  - body
+    #   %U  - URL
+    #   %n  - newline
+    #   %%  - literal "%"
+    def format_pullrequest(pull, format, colorize = false)
+      head, base = pull['head'], pull['base']
+      same_repo = head['repo'] && base['repo'] && head['repo']['full_name'] == base['repo']['full_name']
+      labels = pull['labels'].to_a.map { |label| label['name'] }.join(', ')
+
+      format.gsub(/%(au|[ItHBLSbUn%])/) do
+        case $1
+        when 'I'
+          colorize ? "\e[32m%d\e[m" % pull['number'] : pull['number'].to_s
+        when 't'  then pull['title']
+        when 'S'  then pullrequest_state(pull)
+        when 'b'  then pull['body'].to_s
+        when 'au' then pull['user']['login']
+        when 'H'  then same_repo ? head['ref'] : head['label']
+        when 'B'  then base['ref']
+        when 'L'  then labels
+        when 'U'  then pull['html_url']
+        when 'n'  then "\n"
+        when '%'  then '%'
+        end
+      end
+    end
+
+    # Merges a pull request after checking that GitHub considers it ready.
+    # With `--admin`, pull requests that are blocked by branch protection are
+    # merged anyway.
+    def pr_merge(args)
+      options = {}
+      messages = []
+      pull_arg = nil
+      delete_head = admin = false
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '--merge', '--squash', '--rebase'
+          if options[:merge_method]
+            abort "Aborted: only one of `--merge', `--squash' and `--rebase' can be used"
+          end
+          options[:merge_method] = arg.sub('--', '')
+        when '-m', '--message'       then messages << flags.shift
+        when '-d', '--delete-branch' then delete_head = true
+        when '--admin'               then admin = true
+        else
+          abort "invalid argument: #{arg}" if pull_arg or arg.index('-') == 0
+          pull_arg = arg
+        end
+      end
+
+      project, pull = resolve_pullrequest(pull_arg)
+      pull_id = pull['number']
+      head, base = pull['head'], pull['base']
+
+      abort "Aborted: pull request ##{pull_id} is already merged" if pull['merged']
+      abort "Aborted: pull request ##{pull_id} is closed" if 'closed' == pull['state']
+
+      case pull['mergeable_state']
+      when 'dirty'
+        abort "Aborted: pull request ##{pull_id} has conflicts with #{base['ref']}"
+      when 'draft', 'behind', 'blocked'
+        unless admin
+          case pull['mergeable_state']
+          when 'draft'
+            $stderr.puts "Aborted: pull request ##{pull_id} is still a draft"
+          when 'behind'
+            $stderr.puts "Aborted: the head branch of pull request ##{pull_id} is behind #{base['ref']}"
+          when 'blocked'
+            $stderr.puts "Aborted: pull request ##{pull_id} is blocked by required reviews or status checks"
+            api_client.combined_status(project, head['sha'])['statuses'].each do |status|
+              warn "  #{status['context']}: #{status['state']}" unless 'success' == status['state']
+            end
+          end
+          warn "(use `--admin` to merge anyway)"
+          abort
+        end
+      end
+
+      # as with `git commit`, the first `-m` is the title
+      if messages.any?
+        options[:commit_title], body = read_msg(messages.join("\n\n"))
+        options[:commit_message] = body if body
+      end
+      # refuse to merge commits that were pushed after the checks above
+      options[:sha] = head['sha']
+      api_client.merge_pullrequest(project, pull_id, options)
+      $stdout.puts "Merged pull request ##{pull_id} into #{base['ref']}"
+
+      if delete_head
+        head_repo = head['repo']
+        if head_repo and [project.owner, github_user(project.host)].include?(head_repo['owner']['login'])
+          head_project = project.owned_by(head_repo['owner']['login'])
+          head_project.name = head_repo['name']
+          api_client.delete_branch(head_project, head['ref'])
+          $stdout.puts "Deleted branch #{head['label']}"
+        else
+          warn "Not deleting #{head['label']} as it belongs to someone else's fork"
+        end
+      end
+
+      branch = current_branch
+      upstream = branch && branch.upstream
+      on_head = branch && [branch.short_name, upstream && upstream.short_name].include?(head['ref'])
+      if on_head and $stdin.tty? and $stdout.tty?
+        $stdout.print "Switch to #{base['ref']} and pull? [Y/n] "
+        if $stdin.gets.to_s.strip =~ /^(y(es)?)?$/i
+          args.replace ['checkout', base['ref']]
+          args.after ['pull']
+          return
+        end
+      end
+      args.skip!
+    end
+
+    def pullrequest_state(pull)
+      if pull['merged'] or pull['merged_at'] then 'merged'
+      elsif 'closed' == pull['state'] then 'closed'
+      elsif pull['draft'] then 'draft'
+      else 'open'
+      end
+    end
+
+    # Shows a pull request in the terminal, or with `--web` in the browser.
+    def pr_show(args)
+      pull_arg = format = nil
+      open_url = comments = false
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '-w', '--web'      then open_url = true
+        when '-c', '--comments' then comments = true
+        when '-f', '--format'   then format = flags.shift
+        else
+          abort "invalid argument: #{arg}" if pull_arg or arg.index('-') == 0
+          pull_arg = arg
+        end
+      end
+
+      project, pull = resolve_pullrequest(pull_arg)
+      pull_id = pull['number']
+
+      if open_url
+        args.executable = browser_launcher
+        args.replace [pull['html_url']]
+        return
+      end
+      args.skip!
+
+      if format
+        puts format_pullrequest(pull, format)
+        return
+      end
+
+      state = pullrequest_state(pull)
+      verb = {'merged' => 'merged', 'closed' => 'wanted to merge'}[state] || 'wants to merge'
+
+      lines = []
+      lines << "#{pull['title']} ##{pull['number']}"
+      lines << "#{state_label(state)} - " + format_pullrequest(pull, "%au #{verb} %H into %B")
+      if %w[open draft].include? state
+        checks = api_client.combined_status(project, pull['head']['sha'])
+        ci_state = checks['total_count'].to_i > 0 ? checks['state'] : 'no status'
+        mergeable = case pull['mergeable']
+          when true  then 'yes'
+          when false then 'no (conflicts)'
+          else 'unknown'
+          end
+        lines << "CI: #{ci_state}, mergeable: #{mergeable}"
+      end
+      body = strip_markdown(pull['body'].to_s)
+      lines << "" << body unless body.empty?
+
+      lines.concat comment_lines(api_client.issue_comments(project, pull_id)) if comments
+      lines << "" << pull['html_url']
+      puts lines
+    end
+
+    def comment_lines(comments)
+      comments.inject([]) do |lines, comment|
+        lines << "" << "#{comment['user']['login']} commented on #{comment['created_at'][0, 10]}:"
+        lines << strip_markdown(comment['body'].to_s)
+      end
+    end
+
+    def state_label(state)
+      label = state.capitalize
+      $stdout.tty? ? "\e[#{STATE_COLORS[state]}m#{label}\e[m" : label
+    end
+
+    # Finds the project and number of an issue given either as a number in
+    # the current repo, optionally prefixed with "#", or as a URL.
+    def resolve_issue(arg)
+      abort "Error: an issue number or URL is required" unless arg
+      if arg =~ /^#?(\d+)$/
+        issue_id = $1
+        unless project = local_repo.main_project
+          abort "Aborted: the origin remote doesn't point to a GitHub repository."
+        end
+        [project, issue_id]
+      elsif url = resolve_github_url(arg) and url.project_path =~ /^issues\/(\d+)/
+        [url.project, $1]
+      else
+        abort "Error: #{arg} is not an issue number or URL"
+      end
+    end
+
+    # Reads the issue number and `-m`/`-F` message from the arguments of
+    # an `issue` subcommand.
+    def issue_args(args)
+      issue_arg = message = nil
+      flags = args[2..-1]
+      while arg = flags.shift
+        case arg
+        when '-m', '--message' then message = flags.shift
+        when '-F', '--file'    then message = read_file_arg(flags.shift)
+        else
+          abort "invalid argument: #{arg}" if issue_arg or arg.index('-') == 0
+          issue_arg = arg
+        end
+      end
+      project, number = resolve_issue(issue_arg)
+      [project, number, message && message.strip]
+    end
+
+    # Lists issues of the current repo. The default output lines up
+    # numbers, titles and labels in columns and, on a terminal, shortens
+    # titles to fit its width.
+    def issue_list(args)
+      filters = {}
+      limit = 30
+      format = nil
+      include_pulls = false
+      flags = args[1..-1]
+      flags.shift if 'list' == flags.first
+
+      while arg = flags.shift
+        case arg
+        when '-s', '--state'     then filters[:state] = flags.shift
+        when '-l', '--labels'    then filters[:labels] = flags.shift
+        when '-a', '--assignee'  then filters[:assignee] = flags.shift
+        when '-M', '--milestone' then filters[:milestone] = flags.shift
+        when '-c', '--creator'   then filters[:creator] = flags.shift
+        when '-@', '--mention'   then filters[:mentioned] = flags.shift
+        when '--since'           then filters[:since] = flags.shift
+        when '-o', '--sort'      then filters[:sort] = flags.shift
+        when '-L', '--limit'     then limit = flags.shift.to_i
+        when '-f', '--format'    then format = flags.shift
+        when '--include-pulls'   then include_pulls = true
+        else
+          abort "invalid argument: #{arg}"
+        end
+      end
+
+      if filters[:state] and !PULLREQ_STATES.include?(filters[:state])
+        abort "invalid state: #{filters[:state]} (use one of: #{PULLREQ_STATES.join(', ')})"
+      end
+      if filters[:sort] and !ISSUE_SORTS.include?(filters[:sort])
+        abort "invalid sort: #{filters[:sort]} (use one of: #{ISSUE_SORTS.join(', ')})"
+      end
+      abort "invalid limit: must be a positive number" unless limit > 0
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+      issues = api_client.issues(project, filters, limit, include_pulls)
+
+      if format
+        issues.each { |issue| puts format_issue(issue, format) }
+      elsif issues.any?
+        colorize = $stdout.tty?
+        numbers = issues.map { |issue| "##{issue['number']}" }
+        labels = issues.map { |issue| format_issue(issue, '%l') }
+        number_width = numbers.map { |num| num.length }.max
+        title_width = issues.map { |issue| issue['title'].length }.max
+        if colorize
+          # leave room for the number and labels columns and their separators
+          room = terminal_width - number_width - labels.map { |label| label.length }.max - 6
+          title_width = [title_width, [room, 20].max].min
+        end
+
+        issues.each_with_index do |issue, i|
+          title = issue['title']
+          title = title[0, title_width - 3] + '...' if title.length > title_width
+          number = numbers[i].rjust(number_width)
+          number = "\e[32m#{number}\e[m" if colorize
+          puts "  #{number}  #{title.ljust(title_width)}  #{labels[i]}".rstrip
+        end
+      end
+    end
+
+    # Expands placeholders in an `issue list` format string:
+    #
+    #   %I  - number
+    #   %t  - title
+    #   %S  - state
+    #   %au - login of the author
+    #   %l  - comma-separated label names
+    #   %a  - comma-separated logins of assignees
+    #   %U  - URL
+    #   %cI - creation time, ISO 8601
+    #   %n  - newline
+    #   %%  - literal "%"
+    def format_issue(issue, format)
+      format.gsub(/%(cI|au|[ItSlaUn%])/) do
+        case $1
+        when 'I'  then issue['number'].to_s
+        when 't'  then issue['title']
+        when 'S'  then issue['state']
+        when 'au' then issue['user']['login']
+        when 'l'  then issue['labels'].to_a.map { |label| label['name'] }.join(', ')
+        when 'a'  then issue['assignees'].to_a.map { |user| user['login'] }.join(', ')
+        when 'U'  then issue['html_url']
+        when 'cI' then issue['created_at']
+        when 'n'  then "\n"
+        when '%'  then '%'
+        end
+      end
+    end
+
+    def issue_show(args)
+      comments = args.delete('-c') || args.delete('--comments')
+      project, number = resolve_issue(args.words[2])
+      issue = api_client.issue_info(project, number)
+
+      lines = []
+      lines << "#{issue['title']} ##{issue['number']}"
+      lines << "#{state_label(issue['state'])} - #{issue['user']['login']} opened this on #{issue['created_at'][0, 10]}"
+      labels = issue['labels'].to_a.map { |label| label['name'] }
+      lines << "Labels: #{labels.join(', ')}" if labels.any?
+      assignees = issue['assignees'].to_a.map { |user| user['login'] }
+      lines << "Assignees: #{assignees.join(', ')}" if assignees.any?
+      body = strip_markdown(issue['body'].to_s)
+      lines << "" << body unless body.empty?
+      lines.concat comment_lines(api_client.issue_comments(project, number)) if comments
+      lines << "" << issue['html_url']
+      puts lines
+    end
+
+    def issue_close(args)
+      project, number, message = issue_args(args)
+      api_client.create_issue_comment(project, number, message) if message and !message.empty?
+      api_client.update_issue(project, number, :state => 'closed')
+      $stdout.puts "Closed issue ##{number}"
+    end
+
+    def issue_reopen(args)
+      project, number = resolve_issue(args.words[2])
+      api_client.update_issue(project, number, :state => 'open')
+      $stdout.puts "Reopened issue ##{number}"
+    end
+
+    def issue_comment(args)
+      project, number, message = issue_args(args)
+      abort "Aborting due to empty comment (use `-m` or `-F`)" if message.to_s.empty?
+      comment = api_client.create_issue_comment(project, number, message)
+      $stdout.puts comment['html_url']
+    end
+
+    def issue_create(args)
+      messages = []
+      labels = []
+      assignees = []
+      file_message = milestone = nil
+      open_url = false
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '-m', '--message'   then messages << flags.shift
+        when '-F', '--file'      then file_message = read_file_arg(flags.shift)
+        when '-l', '--labels'    then labels.concat flags.shift.split(',')
+        when '-a', '--assign'    then assignees.concat flags.shift.split(',')
+        when '-M', '--milestone' then milestone = flags.shift
+        when '-o', '--browse'    then open_url = true
+        else
+          abort "invalid argument: #{arg}"
+        end
+      end
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+
+      # `-m` takes precedence over `-F`, just like with `pull-request`
+      if messages.any? or file_message
+        message = messages.any? ? messages.join("\n\n") : file_message
+        title, body = read_msg(message)
+        abort "Aborting due to empty issue title" unless title
+      else
+        template = issue_template
+        title, body = edit_message(issue_editmsg_file, 'issue') { |msg, initial_message|
+          initial_message ||= template
+          msg.puts initial_message if initial_message
+          msg.puts ""
+          msg.puts "# Creating an issue for #{project.name_with_owner}"
+          msg.puts "#"
+          msg.puts "# Write a message for this issue. The first block of"
+          msg.puts "# text is the title and the rest is description."
+        }
+      end
+
+      params = { :title => title }
+      params[:body] = body if body
+      params[:labels] = labels.map { |label| label.strip } if labels.any?
+      params[:assignees] = assignees.map { |user| user.strip } if assignees.any?
+      params[:milestone] = resolve_milestone(project, milestone) if milestone
+
+      issue = api_client.create_issue(project, params)
+      delete_editmsg(issue_editmsg_file)
+
+      args.executable = open_url ? browser_launcher : 'echo'
+      args.replace [issue['html_url']]
+    end
+
+    # Finds the number of a milestone given either its number or title.
+    def resolve_milestone(project, milestone)
+      return milestone.to_i if milestone =~ /^\d+$/
+      found = api_client.milestones(project).find { |m| m['title'].downcase == milestone.downcase }
+      abort "Error: milestone #{milestone.inspect} not found in #{project.name_with_owner}" unless found
+      found['number']
+    end
+
+    # Contents of the repo's issue template, if it has one.
+    def issue_template
+      root = git_command('rev-parse --show-toplevel') or return
+      %w[.github/ISSUE_TEMPLATE.md ISSUE_TEMPLATE.md docs/ISSUE_TEMPLATE.md].each do |path|
+        file = File.join(root, path)
+        return File.read(file).strip if File.file?(file)
+      end
+      nil
+    end
+
+    # Lists releases of the current repo, newest first, with tags and names
+    # lined up in columns and drafts and prereleases marked as such.
+    def release_list(args)
+      limit = 30
+      format = nil
+      include_drafts = false
+      include_prereleases = true
+      flags = args[1..-1]
+      flags.shift if 'list' == flags.first
+
+      while arg = flags.shift
+        case arg
+        when '-d', '--include-drafts'      then include_drafts = true
+        when '-p', '--exclude-prereleases' then include_prereleases = false
+        when '-L', '--limit'               then limit = flags.shift.to_i
+        when '-f', '--format'              then format = flags.shift
+        else
+          abort "invalid argument: #{arg}"
+        end
+      end
+      abort "invalid limit: must be a positive number" unless limit > 0
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+      releases = api_client.releases(project, limit, include_drafts, include_prereleases)
+
+      if format
+        releases.each { |release| puts format_release(release, format) }
+      elsif releases.any?
+        tag_width = releases.map { |release| release['tag_name'].length }.max
+        releases.each do |release|
+          marker = format_release(release, '%S')
+          marker = "(#{marker})" unless marker.empty?
+          puts "#{release['tag_name'].ljust(tag_width)}  #{format_release(release, '%t')}  #{marker}".rstrip
+        end
+      end
+    end
+
+    # Expands placeholders in a `release` format string:
+    #
+    #   %T  - tag name
+    #   %t  - name, or the tag name if the release has none
+    #   %S  - "draft", "prerelease" or empty for regular releases
+    #   %b  - body
+    #   %U  - URL
+    #   %pI - publish time, ISO 8601; empty for drafts
+    #   %n  - newline
+    #   %%  - literal "%"
+    def format_release(release, format)
+      format.gsub(/%(pI|[TtSbUn%])/) do
+        case $1
+        when 'T'  then release['tag_name']
+        when 't'
+          name = release['name'].to_s.strip
+          name.empty? ? release['tag_name'] : name
+        when 'S'
+          if release['draft'] then 'draft'
+          elsif release['prerelease'] then 'prerelease'
+          else ''
+          end
+        when 'b'  then release['body'].to_s
+        when 'U'  then release['html_url']
+        when 'pI' then release['published_at'].to_s
+        when 'n'  then "\n"
+        when '%'  then '%'
+        end
+      end
+    end
+
+    def release_show(args)
+      open_url = args.delete('-w') || args.delete('--web')
+      tag = args.words[2]
+      abort "Usage: hub release show <TAG> [-w]" unless tag
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+
+      release = find_release(project, tag)
+
+      if open_url
+        args.executable = browser_launcher
+        args.replace [release['html_url']]
+        return
+      end
+      args.skip!
+
+      marker = format_release(release, '%S')
+      lines = []
+      lines << "#{format_release(release, '%t')} (#{release['tag_name']})"
+      if release['draft']
+        lines << "Draft - not published yet"
+      else
+        lines << "Published on #{release['published_at'].to_s[0, 10]}#{" - #{marker}" unless marker.empty?}"
+      end
+      body = strip_markdown(release['body'].to_s)
+      lines << "" << body unless body.empty?
+
+      assets = release['assets'].to_a
+      if assets.any?
+        name_width = assets.map { |asset| asset['name'].length }.max
+        lines << "" << "Assets:"
+        assets.each do |asset|
+          downloads = asset['download_count'].to_i
+          lines << "  #{asset['name'].ljust(name_width)}  %9s  %d download%s" %
+            [human_size(asset['size'].to_i), downloads, downloads == 1 ? '' : 's']
+        end
+      end
+      lines << "" << release['html_url']
+      puts lines
+    end
+
+    # Finds the release for a tag. Drafts can't be looked up by tag, so they
+    # are searched for among the latest releases. If there's no release,
+    # exits with suggestions of the most similar tags.
+    def find_release(project, tag)
+      release = api_client.release_by_tag(project, tag) and return release
+      releases = api_client.releases(project, 100, true)
+      release = releases.find { |r| r['tag_name'] == tag } and return release
+
+      tags = releases.map { |r| r['tag_name'] }
+      suggestions = tags.sort_by { |name| [edit_distance(tag, name), tags.index(name)] }.first(3)
+      $stderr.puts "Error: no release found for tag #{tag}"
+      if suggestions.any?
+        $stderr.puts "Did you mean one of these?"
+        suggestions.each { |name| $stderr.puts "    #{name}" }
+      end
+      exit 1
+    end
+
+    # Splits "path/to/file#Label" arguments of `-a` into path and label,
+    # checking that each file exists.
+    def release_assets(args)
+      args.map { |asset|
+        path, label = asset.split('#', 2)
+        abort "Error: #{path} is not a file" unless File.file?(path)
+        [path, label]
+      }
+    end
+
+    # Creates a release and attaches assets to it. While assets upload, the
+    # release is kept as a draft so that it's never published incomplete.
+    def release_create(args)
+      tag = file_message = commitish = nil
+      messages = []
+      assets = []
+      draft = prerelease = generate_notes = open_url = false
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '-m', '--message'    then messages << flags.shift
+        when '-F', '--file'       then file_message = read_file_arg(flags.shift)
+        when '-a', '--attach'     then assets << flags.shift
+        when '-d', '--draft'      then draft = true
+        when '-p', '--prerelease' then prerelease = true
+        when '-t', '--commitish'  then commitish = flags.shift
+        when '--generate-notes'   then generate_notes = true
+        when '-o', '--browse'     then open_url = true
+        else
+          abort "invalid argument: #{arg}" if tag or arg.index('-') == 0
+          tag = arg
+        end
+      end
+      abort "Usage: hub release create <TAG> [options]" unless tag
+
+      assets = release_assets(assets)
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+
+      params = { :tag_name => tag, :draft => draft || assets.any?, :prerelease => prerelease }
+      params[:target_commitish] = commitish if commitish
+
+      if messages.any? or file_message
+        message = messages.any? ? messages.join("\n\n") : file_message
+        params[:name], params[:body] = read_msg(message)
+        abort "Aborting due to empty release title" unless params[:name]
+        # GitHub adds its notes after the given description
+        params[:generate_release_notes] = true if generate_notes
+      else
+        notes = api_client.generate_release_notes(project, tag, commitish) if generate_notes
+        params[:name], params[:body] = edit_message(release_editmsg_file, 'release') { |msg, initial_message|
+          initial_message ||= notes && [notes['name'], notes['body']].join("\n\n")
+          msg.puts initial_message if initial_message
+          msg.puts ""
+          msg.puts "# Creating release #{tag} for #{project.name_with_owner}"
+          msg.puts "#"
+          msg.puts "# Write a message for this release. The first block of"
+          msg.puts "# text is the title and the rest is description."
+        }
+      end
+
+      release = api_client.create_release(project, params)
+      delete_editmsg(release_editmsg_file)
+
+      if assets.any?
+        failed = upload_assets(release, assets)
+        if failed.any?
+          warn "The release was left as a draft. To retry, run:"
+          warn "  " + retry_uploads_command(tag, failed, !draft)
+          exit 1
+        end
+        release = api_client.update_release(project, release['id'], :draft => false) unless draft
+      end
+
+      args.executable = open_url ? browser_launcher : 'echo'
+      args.replace [release['html_url']]
+    end
+
+    def retry_uploads_command(tag, failed, publish)
+      command = "hub release edit #{tag} " + failed.map { |path, label| "-a #{[path, label].compact.join('#')}" }.join(' ')
+      publish ? "#{command} --publish" : command
+    end
+
+    # Edits a release, changing only what's given on the command line. The
+    # text editor for the message opens only if nothing else was given.
+    # Assets are attached before a draft is published.
+    def release_edit(args)
+      tag = file_message = nil
+      messages = []
+      assets = []
+      params = {}
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '-m', '--message'    then messages << flags.shift
+        when '-F', '--file'       then file_message = read_file_arg(flags.shift)
+        when '-a', '--attach'     then assets << flags.shift
+        when '-d', '--draft'      then params[:draft] = true
+        when '--publish'          then params[:draft] = false
+        when '-p', '--prerelease' then params[:prerelease] = true
+        when '--no-prerelease'    then params[:prerelease] = false
+        when '-t', '--commitish'  then params[:target_commitish] = flags.shift
+        else
+          abort "invalid argument: #{arg}" if tag or arg.index('-') == 0
+          tag = arg
+        end
+      end
+      abort "Usage: hub release edit <TAG> [options]" unless tag
+      assets = release_assets(assets)
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+      release = find_release(project, tag)
+
+      if messages.any? or file_message
+        message = messages.any? ? messages.join("\n\n") : file_message
+        params[:name], params[:body] = read_msg(message)
+        abort "Aborting due to empty release title" unless params[:name]
+        params[:body] ||= ''
+      elsif params.empty? and assets.empty?
+        name, body = edit_message(release_editmsg_file, 'release') { |msg, initial_message|
+          initial_message ||= [release['name'], release['body']].reject { |part| part.to_s.empty? }.join("\n\n")
+          msg.puts initial_message
+          msg.puts ""
+          msg.puts "# Editing release #{tag} for #{project.name_with_owner}"
+          msg.puts "#"
+          msg.puts "# Write a message for this release. The first block of"
+          msg.puts "# text is the title and the rest is description."
+        }
+        params[:name] = name unless name == release['name']
+        params[:body] = body.to_s unless body.to_s == release['body'].to_s
+      end
+
+      publish = assets.any? && params[:draft] == false
+      params.delete(:draft) if publish
+      release = api_client.update_release(project, release['id'], params) if params.any?
+      delete_editmsg(release_editmsg_file)
+
+      if assets.any?
+        failed = upload_assets(release, assets)
+        if failed.any?
+          warn publish ? "The release was left as a draft. To retry, run:" : "To retry, run:"
+          warn "  " + retry_uploads_command(tag, failed, publish)
+          exit 1
+        end
+        api_client.update_release(project, release['id'], :draft => false) if publish
+      end
+      $stdout.puts "Updated release #{tag}"
+    end
+
+    def release_delete(args)
+      flags = args[2..-1]
+      yes = flags.delete('-y') || flags.delete('--yes')
+      with_tag = flags.delete('--with-tag')
+      abort "Usage: hub release delete <TAG> [--with-tag] [--yes]" unless flags.size == 1
+      tag = flags.first
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+      release = find_release(project, tag)
+      confirm("delete release #{tag}#{' and its tag' if with_tag}", project) unless yes
+
+      api_client.delete_release(project, release['id'])
+      api_client.delete_tag(project, tag) if with_tag
+      $stdout.puts "Deleted release #{tag}#{' and tag' if with_tag}"
+    end
+
+    # Downloads assets of a release into a directory, showing progress on a
+    # terminal. Nothing is downloaded if any of the files already exists,
+    # unless `--clobber` is given.
+    def release_download(args)
+      tag = pattern = nil
+      dir = '.'
+      clobber = false
+      flags = args[2..-1]
+
+      while arg = flags.shift
+        case arg
+        when '-p', '--pattern' then pattern = flags.shift
+        when '-D', '--dir'     then dir = flags.shift
+        when '--clobber'       then clobber = true
+        else
+          abort "invalid argument: #{arg}" if tag or arg.index('-') == 0
+          tag = arg
+        end
+      end
+      abort "Usage: hub release download <TAG> [--pattern <GLOB>] [--dir <DIR>] [--clobber]" unless tag
+
+      unless project = local_repo.main_project
+        abort "Aborted: the origin remote doesn't point to a GitHub repository."
+      end
+      release = find_release(project, tag)
+
+      assets = release['assets'].to_a
+      assets = assets.select { |asset| File.fnmatch(pattern, asset['name']) } if pattern
+      if assets.empty?
+        abort pattern ? "Error: no assets of release #{tag} match #{pattern}" : "Error: release #{tag} has no assets"
+      end
+
+      paths = assets.map { |asset| File.join(dir, asset['name']) }
+      existing = paths.select { |path| File.exist?(path) }
+      if existing.any? and !clobber
+        abort "Aborted: #{existing.join(', ')} already exist#{'s' if existing.size == 1} (use `--clobber` to overwrite)"
+      end
+
+      require 'fileutils'
+      FileUtils.mkdir_p dir
+      assets.each_with_index do |asset, i|
+        begin
+          File.open(paths[i], 'wb') { |file|
+            api_client.download_release_asset(asset, file) { |done, total|
+              download_progress(asset['name'], done, total)
+            }
+          }
+        rescue Exception
+          # don't leave a partial download behind
+          File.delete(paths[i]) if File.exist?(paths[i])
+          raise
+        end
+        $stderr.print "\r\e[K" if $stderr.tty?
+        $stderr.puts "Downloaded #{paths[i]}"
+      end
+    end
+
+    # Redraws the progress bar of a download on a terminal.
+    def download_progress(name, done, total)
+      return unless $stderr.tty?
+      if total and total > 0
+        percent = done * 100 / total
+        $stderr.print "\r\e[K%s [%-20s] %3d%%" % [name, '#' * (percent / 5), percent]
+      else
+        $stderr.print "\r\e[K#{name} #{human_size(done)}"
+      end
+    end
+
+    # Asks on the terminal before doing something that can't be undone,
+    # such as "delete label \"bug\"". Outside of a terminal there's no one
+    # to ask, so `--yes` has to be given instead.
+    def confirm(action, project)
+      unless $stdin.tty? and $stdout.tty?
+        abort "Aborted: use `--yes` to #{action} without confirmation"
+      end
+      $stdout.print "#{action[0, 1].upcase}#{action[1..-1]} from #{project.name_with_owner}? [y/N] "
+      abort "Aborted." unless $stdin.gets.to_s.strip =~ /^y(es)?$/i
+    end
+
+    # Uploads assets to a release a few at a time, reporting progress on
+    # stderr. Returns the assets that failed to upload.
+    def upload_assets(release, assets)
+      require 'thread'
+      queue = assets.dup
+      failed = []
+      done = 0
+      lock = Mutex.new
+
+      workers = [assets.size, 4].min.times.map {
+        Thread.new do
+          while asset = lock.synchronize { queue.shift }
+            path, label = asset
+            begin
+              api_client.upload_release_asset(release, path, label)
+              lock.synchronize {
+                done += 1
+                $stderr.puts "Attached #{File.basename(path)} (#{done}/#{assets.size})"
+              }
+            rescue GitHubAPI::Exceptions, Context::FatalError
+              message = $!.respond_to?(:response) ? "#{$!.response.message.strip} (HTTP #{$!.response.status})" : $!.message
+              lock.synchronize {
+                failed << asset
+                $stderr.puts "Error uploading #{File.basename(path)}: #{message}"
+              }
+            end
+          end
+        end
+      }
+      workers.each { |worker| worker.join }
+      assets.select { |asset| failed.include?(asset) }
+    end
+
+    # Formats a number of bytes like "512 B", "3.4 KB" or "12.0 MB".
+    def human_size(bytes)
+      return "#{bytes} B" if bytes < 1024
+      size = bytes.to_f
+      %w[KB MB GB].each do |unit|
+        size /= 1024
+        return "%.1f %s" % [size, unit] if size < 1024 or unit == 'GB'
+      end
+    end
+
+    # Levenshtein distance between two strings, for suggesting names close
+    # to one that wasn't found.
+    def edit_distance(a, b)
+      row = (0..b.length).to_a
+      a.each_char.with_index do |char, i|
+        prev, row[0] = row[0], i + 1
+        b.each_char.with_index do |other, j|
+          cost = char == other ? 0 : 1
+          prev, row[j + 1] = row[j + 1], [row[j + 1] + 1, row[j] + 1, prev + cost].min
+        end
+      end
+      row[b.length]
+    end
+
+    # Lists labels with their colors and descriptions, lined up in columns.
+    # On a terminal, each line starts with a swatch of the label's color.
+    def label_list(project)
+      labels = api_client.labels(project)
+      return if labels.empty?
+      colorize = $stdout.tty?
+      width = labels.map { |label| label['name'].length }.max
+
+      labels.each do |label|
+        swatch = colorize ? color_swatch(label['color']) + ' ' : ''
+        puts "#{swatch}#{label['name'].ljust(width)}  ##{label['color']}  #{label['description']}".rstrip
+      end
+    end
+
+    # Two spaces with the given background color, for terminals that
+    # support 24-bit colors.
+    def color_swatch(hex)
+      red, green, blue = hex.scan(/../).map { |pair| pair.hex }
+      "\e[48;2;#{red};#{green};#{blue}m  \e[m"
+    end
+
+    def label_create(project, flags)
+      name = description = nil
+      color = 'ededed'
+      while arg = flags.shift
+        case arg
+        when '-c', '--color'       then color = flags.shift.to_s.sub(/^#/, '')
+        when '-d', '--description' then description = flags.shift
+        else
+          abort "invalid argument: #{arg}" if name or arg.index('-') == 0
+          name = arg
+        end
+      end
+      abort "Error: a label name is required" unless name
+      abort "invalid color: #{color} (use a hex code like ff0000)" unless color =~ /\A[0-9a-f]{6}\z/i
+
+      api_client.create_label(project, name, color.downcase, description)
+      $stdout.puts "Created label #{name}"
+    end
+
+    def label_delete(project, flags)
+      yes = flags.delete('-y') || flags.delete('--yes')
+      abort "Usage: hub label delete <NAME> [--yes]" unless flags.size == 1
+      name = flags.first
+
+      confirm("delete label #{name.inspect}", project) unless yes
+
+      api_client.delete_label(project, name)
+      $stdout.puts "Deleted label #{name}"
+    end
+
+    def label_rename(project, flags)
+      abort "Usage: hub label rename <OLD> <NEW>" unless flags.size == 2
+      old_name, new_name = flags
+      api_client.update_label(project, old_name, :new_name => new_name)
+      $stdout.puts "Renamed label #{old_name} to #{new_name}"
+    end
+
+    # Creates labels that another repo has but this one doesn't. Labels
+    # that exist in both, compared case-insensitively like GitHub does,
+    # are only brought in line with the other repo's color and description
+    # with `--force`.
+    def label_copy(project, flags)
+      force = flags.delete('-f') || flags.delete('--force')
+      abort "Usage: hub label --copy-from <OWNER/REPO> [--force]" unless flags.size == 1 and flags.first.index('/')
+      source = github_project(flags.first)
+
+      existing = {}
+      api_client.labels(project).each { |label| existing[label['name'].downcase] = label }
+
+      api_client.labels(source).each do |label|
+        if current = existing[label['name'].downcase]
+          next unless force
+          next if current['color'] == label['color'] and current['description'] == label['description']
+          api_client.update_label(project, current['name'],
+            :color => label['color'], :description => label['description'])
+          $stdout.puts "Updated label #{current['name']}"
+        else
+          api_client.create_label(project, label['name'], label['color'], label['description'])
+          $stdout.puts "Created label #{label['name']}"
+        end
+      end
+    end
+
+    # Simplifies Markdown for reading in the terminal: drops HTML comments,
+    # heading markers and emphasis, and shows links as "text (url)".
+    def strip_markdown(text)
+      text.gsub("\r\n", "\n").
+        gsub(/<!--.*?-->/m, '').
+        gsub(/^\#{1,6}\s+/, '').
+        gsub(/!?\[([^\]]*)\]\(([^)\s]+)\)/, '\1 (\2)').
+        gsub(/(\*\*|__)(.+?)\1/, '\2').
+        gsub(/`([^`\n]+)`/, '\1').
+        strip
+    end
+
+    # Checks out the head of a pull request into a local branch. Running it
+    # again for the same pull request fast-forwards that branch.
+    def pr_checkout(args)
+      pull_arg, new_branch_name = args.words[2, 2]
+      abort "Usage: hub pr checkout <PULLREQ-NUMBER|PULLREQ-URL|BRANCH> [<NEW-BRANCH>]" unless pull_arg
+
+      project, pull_data = resolve_pullrequest(pull_arg)
+      pull_id = pull_data['number']
+      user, branch = pull_data['head']['label'].split(':', 2)
+      head_repo = pull_data['head']['repo']
+      base_remote = remotes.find { |remote|
+        remote_project = remote.project and remote_project.name_with_owner == project.name_with_owner
+      } || origin_remote
+
+      if head_repo.nil?
+        # the fork is gone, but GitHub keeps the head of its pull requests
+        new_branch_name ||= "pr-#{pull_id}"
+        pull_ref = "refs/pull/#{pull_id}/head"
+        start_point = 'FETCH_HEAD'
+      elsif head_repo['full_name'] == pull_data['base']['repo']['full_name']
+        new_branch_name ||= branch
+        start_point = "#{base_remote}/#{branch}"
+        args.before ['fetch', base_remote.to_s, "+refs/heads/#{branch}:refs/remotes/#{start_point}"]
+      else
+        new_branch_name ||= "#{user}-#{branch}"
+        start_point = "#{user}/#{branch}"
+        if remotes.include? user
+          args.before ['remote', 'set-branches', '--add', user, branch]
+          args.before ['fetch', user, "+refs/heads/#{branch}:refs/remotes/#{start_point}"]
+        else
+          url = github_project(head_repo['name'], user).git_url(:private => head_repo['private'],
+                                                                :https => https_protocol?)
+          args.before ['remote', 'add', '-f', '-t', branch, user, url]
+        end
+
+        if pull_data['maintainer_can_modify']
+          # have `git push` update the contributor's branch
+          push_refspec = "refs/heads/#{new_branch_name}:refs/heads/#{branch}"
+          unless git_config("remote.#{user}.push", :all).to_s.split("\n").include? push_refspec
+            args.before ['config', '--add', "remote.#{user}.push", push_refspec]
+          end
+        end
+      end
+
+      if git_command("rev-parse -q --verify refs/heads/#{new_branch_name}")
+        args.before ['fetch', base_remote.to_s, pull_ref] if pull_ref
+        args.replace ['checkout', new_branch_name]
+        args.after ['merge', '--ff-only', start_point]
+      elsif pull_ref
+        args.before ['fetch', base_remote.to_s, "#{pull_ref}:refs/heads/#{new_branch_name}"]
+        args.replace ['checkout', new_branch_name]
+      else
+        args.replace ['checkout', '--track', '-b', new_branch_name, start_point]
+      end
+    end
+
+    # Returns the terminal-formatted manpage, ready to be printed to
+    # the screen.
+    def hub_manpage
+      abort "** Can't find groff(1)" unless command?('groff')
+
+      require 'open3'
+      out = nil
+      Open3.popen3(groff_command) do |stdin, stdout, _|
+        stdin.puts hub_raw_manpage
+        stdin.close
+        out = stdout.read.strip
+      end
+      out
+    end
+
+    # The groff command complete with crazy arguments we need to run
+    # in order to turn our raw roff (manpage markup) into something
+    # readable on the terminal.
+    def groff_command
+      cols = terminal_width
+      "groff -Wall -mtty-char -mandoc -Tascii -rLL=#{cols}n -rLT=#{cols}n"
+    end
+
+    # Returns the raw hub manpage. If we're not running in standalone
+    # mode, it's a file sitting at the root under the `man`
+    # directory.
+    #
+    # If we are running in standalone mode the manpage will be
+    # included after the __END__ of the file so we can grab it using
+    # DATA.
+    def hub_raw_manpage
+      if File.exists? file = File.dirname(__FILE__) + '/../../man/hub.1'
+        File.read(file)
+      else
+        DATA.read
+      end
+    end
+
+    # All calls to `puts` in after hooks or commands are paged,
+    # git-style.
+    def puts(*args)
+      page_stdout
+      super
+    end
+
+    # http://nex-3.com/posts/73-git-style-automatic-paging-in-ruby
+    def page_stdout
+      return if not $stdout.tty? or windows?
+
+      read, write = IO.pipe
+
+      if Kernel.fork
+        # Parent process, become pager
+        $stdin.reopen(read)
+        read.close
+        write.close
+
+        # Don't page if the input is short enough
+        ENV['LESS'] = 'FSRX'
+
+        # Wait until we have input before we start the pager
+        Kernel.select [STDIN]
+
+        pager = ENV['GIT_PAGER'] ||
+          `git config --get-all core.pager`.split("\n").first ||
+          ENV['PAGER'] ||
+          'less -isr'
+
+        pager = 'cat' if pager.empty?
+
+        exec pager rescue exec "/bin/sh", "-c", pager
+      else
+        # Child process
+        $stdout.reopen(write)
+        $stderr.reopen(write) if $stderr.tty?
+        read.close
+        write.close
+      end
+    rescue NotImplementedError
+      # fork might not available, such as in JRuby
+    end
+
+    def pullrequest_editmsg(changes, &block)
+      edit_message(pullrequest_editmsg_file, 'pull request', changes, &block)
+    end
+
+    # Writes a message file, yielding it together with any message left
+    # over from a previous failed attempt, opens it in the git editor and
+    # returns the title and body read back from it.
+    def edit_message(message_file, what, changes = nil)
+      if valid_editmsg_file?(message_file)
+        title, body = read_editmsg(message_file)
+        previous_message = [title, body].compact.join("\n\n") if title
+      end
+
+      File.open(message_file, 'w') { |msg|
+        yield msg, previous_message
+        if changes
+          msg.puts "#\n# Changes:\n#"
+          msg.puts changes.gsub(/^/, '# ').gsub(/ +$/, '')
+        end
+      }
+
+      edit_cmd = Array(git_editor).dup
+      edit_cmd << '-c' << 'set ft=gitcommit tw=0 wrap lbr' if edit_cmd[0] =~ /^[mg]?vim$/
+      edit_cmd << message_file
+      system(*edit_cmd)
+
+      unless $?.success?
+        # writing was cancelled, or the editor never opened in the first place
+        delete_editmsg(message_file)
+        abort "error using text editor for #{what} message"
+      end
+
+      title, body = read_editmsg(message_file)
+      abort "Aborting due to empty #{what} title" unless title
+      [title, body]
+    end
+
+    # This unfortunate hack is because older versions of hub never cleaned up
+    # the pullrequest_editmsg_file, which newer hub would pick up and
+    # misinterpret as a message which should be reused after a failed PR.
+    def valid_editmsg_file?(message_file)
       File.exists?(message_file) &&
         File.mtime(message_file) > File.mtime(__FILE__)
     end
 
+    # Contents of a file given as an argument, or of standard input for "-".
+    # Aborts when the file can't be read.
+    def read_file_arg(file)
+      '-' == file ? $stdin.read : File.read(file)
+    rescue SystemCallError
+      abort "Error: can't read #{file} (#{$!.message})"
+    end
+
     def read_msg(message)
-      message.split("\n\n", 2).each {|s| s.strip! }.reject {|s| s.empty? }
+      message = message.gsub("\r\n", "\n").gsub(/[ \t]+$/, '')
+      title, body = message.split("\n\n", 2).each {|s| s.strip! }.reject {|s| s.empty? }
+      title.tr!("\n", ' ') if title
+      [title, body]
     end
 
     def pullrequest_editmsg_file
       File.join(git_dir, 'PULLREQ_EDITMSG')
     end
 
+    def issue_editmsg_file
+      File.join(git_dir, 'ISSUE_EDITMSG')
+    end
+
+    def release_editmsg_file
+      File.join(git_dir, 'RELEASE_EDITMSG')
+    end
+
     def read_editmsg(file)
       title, body = '', ''
       File.open(file, 'r') { |msg|
@@ -1098,6 +2991,16 @@ help
     end
 
     def display_api_exception(action, response)
+      if 451 == response.status
+        # e.g. a repository taken down due to a DMCA notice
+        $stderr.puts "Error #{action}: repository access blocked (HTTP 451)"
+        if response.data? and block = response.data['block']
+          warn "Reason: #{block['reason']}" if block['reason']
+          warn "See #{block['html_url']}" if block['html_url']
+        end
+        return
+      end
+
       $stderr.puts "Error #{action}: #{response.message.strip} (HTTP #{response.status})"
       if 422 == response.status and response.error_message?
         # display validation errors
@@ -76,7 +76,10 @@ module Hub
     end
 
     class Error < RuntimeError; end
-    class FatalError < Error; end
+    class FatalError < Error
+      # The lower-level exception that this error explains, if any.
+      attr_accessor :original_error
+    end
 
     private
 
@@ -195,8 +198,20 @@ module Hub
         hosts << "ssh.#{default_host}"
       end
 
+      class << self
+        # host given with the `--host` global flag
+        attr_accessor :host_override
+      end
+
+      # Host that was asked for explicitly, with the `--host` flag taking
+      # precedence over $GITHUB_HOST.
+      def self.explicit_host
+        host = host_override || ENV['GITHUB_HOST']
+        host unless host.to_s.empty?
+      end
+
       def self.default_host
-        ENV['GITHUB_HOST'] || main_host
+        explicit_host || main_host
       end
 
       def self.main_host
@@ -446,11 +461,12 @@ module Hub
     end
 
     module System
-      # Cross-platform web browser command; respects the value set in $BROWSER.
-      # 
+      # Cross-platform web browser command; respects the value set in $BROWSER
+      # and then the "hub.browser" git config.
+      #
       # Returns an array, e.g.: ['open']
       def browser_launcher
-        browser = ENV['BROWSER'] || (
+        browser = ENV['BROWSER'] || configured_browser || (
           osx? ? 'open' : windows? ? %w[cmd /c start] :
           %w[xdg-open cygstart x-www-browser firefox opera mozilla netscape].find { |comm| which comm }
         )
@@ -459,6 +475,25 @@ module Hub
         Array(browser)
       end
 
+      def configured_browser
+        browser = git_config('hub.browser') if respond_to?(:git_config, true)
+        browser.shellsplit if browser
+      end
+
+      # Cross-platform command that puts its standard input on the clipboard.
+      #
+      # Returns an array, e.g.: ['pbcopy']
+      def clipboard_command
+        if osx? then %w[pbcopy]
+        elsif windows? then %w[clip]
+        elsif ENV['WAYLAND_DISPLAY'] and command?('wl-copy') then %w[wl-copy]
+        elsif command?('xclip') then %w[xclip -selection clipboard]
+        elsif command?('xsel') then %w[xsel --clipboard --input]
+        else
+          abort "Please install xclip, xsel or wl-copy to copy to the clipboard."
+        end
+      end
+
       def osx?
         require 'rbconfig'
         RbConfig::CONFIG['host_os'].to_s.include?('darwin')
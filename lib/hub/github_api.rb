@@ -18,106 +18,1655 @@ module Hub
   #     GitHubAPI.new file_config, :app_url => 'http://hub.github.com/'
   #   end
   class GitHubAPI
-    attr_reader :config, :oauth_app_url
+    attr_reader :config, :oauth_app_url, :per_page
+
+    # Largest page size that the API accepts for list requests.
+    MAX_PER_PAGE = 100
 
     # Public: Create a new API client instance
     #
     # Options:
-    # - config: an object that implements:
-    #   - username(host)
-    #   - api_token(host, user)
-    #   - password(host, user)
-    #   - oauth_token(host, user)
-    def initialize config, options
-      @config = config
-      @oauth_app_url = options.fetch(:app_url)
+    # - config: an object that implements:
+    #   - username(host)
+    #   - api_token(host, user)
+    #   - password(host, user)
+    #   - oauth_token(host, user)
+    #   - api_base_url(host)
+    #   - api_path_prefix(api_host)
+    # - per_page: number of items to request per page in list requests
+    #   (default: the API default)
+    def initialize config, options
+      @config = config
+      @oauth_app_url = options.fetch(:app_url)
+      per_page = options[:per_page].to_i
+      @per_page = [per_page, MAX_PER_PAGE].min if per_page > 0
+    end
+
+    # Fake exception type for net/http exception handling.
+    # Necessary because net/http may or may not be loaded at the time.
+    module Exceptions
+      def self.===(exception)
+        exception.class.ancestors.map {|a| a.to_s }.include? 'Net::HTTPExceptions'
+      end
+    end
+
+    # Public: Host name that the API of a GitHub host is served from.
+    def api_host host
+      URI.parse(config.api_base_url(host)).host
+    end
+
+    # Public: Full URL of an API endpoint on the given host, built from the
+    # API base URL of that host.
+    #
+    # Examples
+    #
+    #   api_url 'github.com', 'user'
+    #   # => "https://api.github.com/user"
+    #
+    #   api_url 'git.my.org', 'user'   # with "protocol: http" configured
+    #   # => "http://git.my.org/api/v3/user"
+    def api_url host, path
+      "#{config.api_base_url(host)}/#{path}"
+    end
+
+    # Public: URL of the GraphQL endpoint of a host, which Enterprise serves
+    # next to the REST API at "/api/graphql".
+    def graphql_url host
+      config.api_base_url(host).sub(%r{/v3$}, '') + '/graphql'
+    end
+
+    # Appends the configured page size to the URL of a list request.
+    def paginated url
+      return url unless per_page
+      "#{url}#{url.index('?') ? '&' : '?'}per_page=#{per_page}"
+    end
+
+    # Appends params that aren't nil to the URL as a query string, in
+    # alphabetical order of their names.
+    def with_query url, params
+      require 'cgi'
+      pairs = params.reject { |key, value| value.nil? }.
+        map { |key, value| "#{key}=#{CGI.escape(value.to_s)}" }.sort
+      return url if pairs.empty?
+      "#{url}#{url.index('?') ? '&' : '?'}#{pairs.join('&')}"
+    end
+
+    # Fetches every page of a list request by following "next" links.
+    #
+    # Options:
+    # - key: name of the list for endpoints that wrap it in an object
+    # - select: lambda that tells which items to keep
+    # - limit: stop after collecting this many items
+    #
+    # Returns the items of all pages.
+    def get_all url, options = {}, &block
+      key, select, limit = options[:key], options[:select], options[:limit]
+      items = []
+      url = paginated(url)
+      while url
+        res = get(url, &block)
+        res.error! unless res.success?
+        page = key ? res.data[key] : res.data
+        items.concat(select ? page.select(&select) : page)
+        break if limit and items.size >= limit
+        url = res.next_page_url
+      end
+      limit ? items.first(limit) : items
+    end
+
+    # Public: Version of the GitHub Enterprise instance at host, or nil for
+    # github.com. Looked up once per host.
+    def enterprise_version host
+      return if api_host(host) == 'api.github.com'
+      @enterprise_versions ||= {}
+      @enterprise_versions.fetch(host) do
+        res = get api_url(host, "meta")
+        res.error! unless res.success?
+        version = res['X-GitHub-Enterprise-Version'] || res.data['installed_version']
+        @enterprise_versions[host] = version
+      end
+    end
+
+    # Public: Whether the instance at host is github.com or an Enterprise
+    # instance at least as new as `minimum`.
+    def enterprise_version_at_least? host, minimum
+      version = enterprise_version(host)
+      version.nil? or self.class.version_at_least?(version, minimum)
+    end
+
+    # Public: Abort with an explanation when the Enterprise instance at host
+    # is older than the version that introduced a feature.
+    #
+    # Examples
+    #
+    #   require_enterprise_version 'git.my.org', '3.1', 'auto-merge'
+    def require_enterprise_version host, minimum, feature
+      unless enterprise_version_at_least?(host, minimum)
+        raise Context::FatalError,
+          "your GitHub Enterprise #{enterprise_version(host)} does not support #{feature}"
+      end
+    end
+
+    # Compares dotted version strings numerically, so that "2.19" is newer
+    # than "2.9" and "3.0" is newer than "2.22".
+    def self.version_at_least? version, minimum
+      to_parts = lambda { |v| v.to_s.scan(/\d+/).map { |n| n.to_i } }
+      have, want = to_parts.call(version), to_parts.call(minimum)
+      want.each_with_index do |part, i|
+        diff = have[i].to_i <=> part
+        return diff > 0 unless diff.zero?
+      end
+      true
+    end
+
+    # Public: Run a GraphQL query or mutation on a host.
+    #
+    # allow_missing - don't fail when all errors are about objects that
+    #                 don't exist; their fields are nil in the data
+    #
+    # Returns the "data" part of the response.
+    def graphql host, query, variables = {}, allow_missing = false
+      res = post graphql_url(host), :query => query, :variables => variables
+      res.error! unless res.success?
+      if errors = res.data['errors'] and
+          !(allow_missing and errors.all? { |err| 'NOT_FOUND' == err['type'] })
+        raise Context::FatalError, errors.map { |err| err['message'] }.join("\n")
+      end
+      res.data['data']
+    end
+
+    # HTTP methods that `api_request` accepts.
+    REQUEST_METHODS = %w[GET HEAD POST PATCH PUT DELETE]
+
+    # Public: Perform a request to an arbitrary API endpoint, as done by
+    # `hub api`. Unlike other methods, error responses are returned rather
+    # than raised.
+    #
+    # method  - one of REQUEST_METHODS
+    # path    - endpoint path such as "repos/defunkt/hub/issues", "graphql",
+    #           or a full URL like the "next" link of a previous response
+    # params  - Hash sent as the JSON body, or as the query string of GET
+    #           and HEAD requests
+    # headers - Hash of extra request headers, e.g. a preview "Accept" type
+    def api_request host, method, path, params = nil, headers = {}
+      unless REQUEST_METHODS.include?(method)
+        raise ArgumentError, "invalid HTTP method: #{method}"
+      end
+      url = if path =~ %r{^https?://} then path
+        elsif 'graphql' == path then graphql_url(host)
+        else api_url(host, path.sub(%r{^/}, ''))
+        end
+      if params and %w[GET HEAD].include?(method)
+        url = with_query(url, params)
+        params = nil
+      end
+
+      type = method.capitalize.to_sym
+      set_headers = lambda { |req| headers.each { |name, value| req[name] = value } }
+      if params
+        perform_request_with_body url, type, params, &set_headers
+      else
+        perform_request url, type, &set_headers
+      end
+    end
+
+    # Public: Look up the login that the stored OAuth token for a host belongs
+    # to and save it to the config entry, which lacked it.
+    def current_user_login host
+      res = get api_url(host, 'user')
+      res.error! unless res.success?
+      login = res.data['login']
+      config.update_username(host, nil, login)
+      login
+    end
+
+    # Public: Fetch data for a specific repo.
+    def repo_info project
+      get api_url(project.host, "repos/%s/%s" % [project.owner, project.name])
+    end
+
+    # Public: Name of the default branch of a repo, which isn't necessarily
+    # "master". Looked up once per repo.
+    def default_branch project
+      @default_branches ||= {}
+      key = [project.host, project.owner, project.name].join('/').downcase
+      @default_branches.fetch(key) do
+        res = repo_info(project)
+        res.error! unless res.success?
+        @default_branches[key] = res.data['default_branch']
+      end
+    end
+
+    # Public: Compare two commits, e.g. "master...feature" or
+    # "mislav:master...defunkt:feature" across forks.
+    #
+    # Returns parsed data with "ahead_by", "behind_by", "total_commits" and
+    # up to 250 "commits", oldest first.
+    def compare project, range
+      res = get api_url(project.host, "repos/%s/%s/compare/%s" % [project.owner, project.name, range])
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Determine whether a specific repo exists.
+    def repo_exists? project
+      repo_info(project).success?
+    end
+
+    # Public: Whether the authenticated user can push to a repo, for choosing
+    # between pushing a branch and forking first.
+    def can_push? project
+      res = repo_info(project)
+      res.error! unless res.success?
+      permissions = res.data['permissions'] || {}
+      permissions['push'] == true
+    end
+
+    # Public: Check which community health files a repo has.
+    #
+    # Returns a Hash with "health_percentage" and true or false for each of
+    # "readme", "license", "contributing", "code_of_conduct",
+    # "issue_template" and "pull_request_template".
+    def community_health project
+      res = get api_url(project.host, "repos/%s/%s/community/profile" % [project.owner, project.name])
+      res.error! unless res.success?
+      files = res.data['files'] || {}
+      health = { 'health_percentage' => res.data['health_percentage'] }
+      %w[readme license contributing code_of_conduct issue_template pull_request_template].each do |name|
+        health[name] = !files[name].nil?
+      end
+      health
+    end
+
+    # Public: Languages used in a repo.
+    #
+    # Returns a Hash of language names to the number of bytes of code
+    # written in each, e.g. {"Ruby" => 12345, "Shell" => 678}.
+    def languages project
+      res = get api_url(project.host, "repos/%s/%s/languages" % [project.owner, project.name])
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Identify the license of a repo from its license file.
+    #
+    # Returns a Hash with "key", "name", "spdx_id", "html_url" and the
+    # Base64-encoded "content" of the file, or nil if there's no license.
+    def license project
+      res = get api_url(project.host, "repos/%s/%s/license" % [project.owner, project.name])
+      return nil if 404 == res.status
+      res.error! unless res.success?
+      info = res.data['license'] || {}
+      { 'key' => info['key'], 'name' => info['name'], 'spdx_id' => info['spdx_id'],
+        'html_url' => res.data['html_url'], 'content' => res.data['content'] }
+    end
+
+    # Public: Star a repo as the authenticated user.
+    def star_repo project
+      res = put api_url(project.host, "user/starred/%s/%s" % [project.owner, project.name])
+      res.error! unless res.success?
+    end
+
+    # Public: Unstar a repo as the authenticated user. A repo that wasn't
+    # starred to begin with isn't an error.
+    def unstar_repo project
+      res = delete api_url(project.host, "user/starred/%s/%s" % [project.owner, project.name])
+      res.error! unless res.success? or 404 == res.status
+    end
+
+    # Public: Watch a repo, or mute its notifications with ignored = true.
+    #
+    # Returns parsed data from the subscription.
+    def set_subscription project, subscribed, ignored
+      res = put api_url(project.host, "repos/%s/%s/subscription" % [project.owner, project.name]),
+        :subscribed => !!subscribed, :ignored => !!ignored
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Stop watching a repo.
+    def delete_subscription project
+      res = delete api_url(project.host, "repos/%s/%s/subscription" % [project.owner, project.name])
+      res.error! unless res.success?
+    end
+
+    # Public: Fork the specified repo.
+    def fork_repo project
+      res = post api_url(project.host, "repos/%s/%s/forks" % [project.owner, project.name])
+      res.error! unless res.success?
+    end
+
+    # Public: Create a new project.
+    def create_repo project, options = {}
+      is_org = project.owner.downcase != config.username(api_host(project.host)).downcase
+      params = { :name => project.name, :private => !!options[:private] }
+      params[:description] = options[:description] if options[:description]
+      params[:homepage]    = options[:homepage]    if options[:homepage]
+
+      if is_org
+        res = post api_url(project.host, "orgs/%s/repos" % project.owner), params
+      else
+        res = post api_url(project.host, "user/repos"), params
+      end
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Merge settings of a repo that can be read and changed through the API.
+    MERGE_SETTINGS = %w[allow_squash_merge allow_merge_commit allow_rebase_merge delete_branch_on_merge]
+
+    # Public: Fetch the merge settings of a repo, e.g.
+    # {"allow_squash_merge" => true, "allow_merge_commit" => false, ...}
+    def repo_merge_settings project
+      res = repo_info(project)
+      res.error! unless res.success?
+      settings = {}
+      MERGE_SETTINGS.each { |key| settings[key] = res.data[key] if res.data.key?(key) }
+      settings
+    end
+
+    # Public: Change merge settings of a repo. Only the settings present in
+    # the hash are sent, so a setting given as `false` is turned off while
+    # one that is left out stays as it is.
+    #
+    # Examples
+    #
+    #   update_repo_merge_settings project, :allow_squash_merge => true,
+    #     :allow_merge_commit => false, :allow_rebase_merge => false
+    #
+    # Returns parsed data from the updated repo.
+    def update_repo_merge_settings project, settings
+      params = { :name => project.name }
+      settings.each do |key, value|
+        unless MERGE_SETTINGS.include?(key.to_s)
+          raise ArgumentError, "unknown merge setting: #{key}"
+        end
+        params[key.to_sym] = !!value
+      end
+
+      res = patch api_url(project.host, "repos/%s/%s" % [project.owner, project.name]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Make a repo private or public.
+    #
+    # Returns parsed data from the updated repo.
+    def set_repo_visibility project, private
+      res = patch api_url(project.host, "repos/%s/%s" % [project.owner, project.name]),
+        :private => !!private
+
+      if 403 == res.status
+        reason = res.data? && res.data['message'] || res.message
+        raise Context::FatalError, "not allowed to make %s %s (%s); " \
+          "organization policy may forbid it" %
+          [project.name_with_owner, private ? 'private' : 'public', reason]
+      end
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Download a tarball or zipball of a repo at the given ref into
+    # `io`.
+    #
+    # format - "tarball" or "zipball"
+    def download_archive project, ref, io, format = 'tarball'
+      download api_url(project.host, "repos/%s/%s/%s/%s" %
+        [project.owner, project.name, format, ref]), io
+    end
+
+    # Public: List releases of a repo, newest first. Drafts, which only
+    # users with push access can see, are left out unless include_drafts
+    # is true.
+    #
+    # limit - stop after fetching this many releases
+    def releases project, limit = nil, include_drafts = false, include_prereleases = true
+      select = lambda { |release|
+        (include_drafts or !release['draft']) and (include_prereleases or !release['prerelease'])
+      }
+      get_all api_url(project.host, "repos/%s/%s/releases" % [project.owner, project.name]),
+        :select => select, :limit => limit
+    end
+
+    # Public: Fetch the published release for a tag, or nil if there is none.
+    def release_by_tag project, tag
+      res = get api_url(project.host, "repos/%s/%s/releases/tags/%s" %
+        [project.owner, project.name, tag])
+      return nil if 404 == res.status
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Create a release. If the tag doesn't exist yet, GitHub creates
+    # it at :target_commitish, or at the default branch when that's absent.
+    #
+    # params - Hash with :tag_name and any of :name, :body, :draft,
+    #          :prerelease, :target_commitish and :generate_release_notes
+    #
+    # Returns parsed data from the new release.
+    def create_release project, params
+      if params[:generate_release_notes]
+        require_enterprise_version project.host, '3.4', 'generated release notes'
+      end
+      res = post api_url(project.host, "repos/%s/%s/releases" % [project.owner, project.name]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Edit a release. Only the fields present in `params` are
+    # changed; they're the same as for create_release.
+    #
+    # Returns parsed data from the updated release.
+    def update_release project, release_id, params
+      res = patch api_url(project.host, "repos/%s/%s/releases/%d" %
+        [project.owner, project.name, release_id]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Delete a release. Its tag is left in place.
+    def delete_release project, release_id
+      res = delete api_url(project.host, "repos/%s/%s/releases/%d" %
+        [project.owner, project.name, release_id])
+      res.error! unless res.success?
+    end
+
+    # Public: Delete a tag from a repo.
+    def delete_tag project, tag
+      res = delete api_url(project.host, "repos/%s/%s/git/refs/tags/%s" %
+        [project.owner, project.name, tag])
+      res.error! unless res.success?
+    end
+
+    # Public: Download a release asset into `io`, yielding progress like
+    # `download` does.
+    def download_release_asset asset, io, &progress
+      download asset['url'], io, &progress
+    end
+
+    # Public: Have GitHub write release notes from the pull requests merged
+    # since the previous release.
+    #
+    # Returns a Hash with the suggested "name" and "body".
+    def generate_release_notes project, tag, target_commitish = nil
+      require_enterprise_version project.host, '3.4', 'generated release notes'
+      params = { :tag_name => tag }
+      params[:target_commitish] = target_commitish if target_commitish
+      res = post api_url(project.host, "repos/%s/%s/releases/generate-notes" %
+        [project.owner, project.name]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Content types for common release asset extensions.
+    ASSET_CONTENT_TYPES = {
+      '.gz' => 'application/gzip', '.tgz' => 'application/gzip',
+      '.zip' => 'application/zip', '.json' => 'application/json',
+      '.txt' => 'text/plain', '.md' => 'text/markdown',
+    }
+
+    # Public: Upload a file to a release.
+    #
+    # label - shown on the release page instead of the file name
+    #
+    # Returns parsed data from the new asset.
+    def upload_release_asset release, path, label = nil
+      url = with_query(release['upload_url'].sub(/\{.*\}$/, ''),
+        :name => File.basename(path), :label => label)
+      content = File.open(path, 'rb') { |file| file.read }
+      res = post(url) { |req|
+        req['Content-Type'] = ASSET_CONTENT_TYPES[File.extname(path).downcase] || 'application/octet-stream'
+        req.body = content
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Page views of a repo over the last 14 days. Traffic is only
+    # visible to users with push access; a FatalError is raised otherwise.
+    #
+    # per - "day" or "week"; GitHub groups by day when nil
+    #
+    # Returns a Hash with "count", "uniques" and "views", a list of
+    # per-period counts each with a "timestamp".
+    def traffic_views project, per = nil
+      traffic project, 'views', per
+    end
+
+    # Public: Clones of a repo over the last 14 days, grouped like
+    # traffic_views. The per-period counts are under "clones".
+    def traffic_clones project, per = nil
+      traffic project, 'clones', per
+    end
+
+    # Public: Top 10 sites that referred visitors to a repo over the last
+    # 14 days, each with "referrer", "count" and "uniques".
+    def traffic_referrers project
+      traffic project, 'popular/referrers', nil
+    end
+
+    # Public: Top 10 most visited pages of a repo over the last 14 days,
+    # each with "path", "title", "count" and "uniques".
+    def traffic_paths project
+      traffic project, 'popular/paths', nil
+    end
+
+    def traffic project, type, per
+      if per and !%w[day week].include?(per.to_s)
+        raise ArgumentError, "invalid traffic period: #{per} (use day or week)"
+      end
+      url = api_url(project.host, "repos/%s/%s/traffic/%s" % [project.owner, project.name, type])
+      res = get with_query(url, :per => per)
+      # other 403s, such as from rate limiting, are reported as they are
+      if 403 == res.status and res.data? and res.data['message'].to_s =~ /push access/i
+        raise Context::FatalError, "push access to %s is required to view its traffic" %
+          project.name_with_owner
+      end
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Fetch info about an issue.
+    def issue_info project, number
+      res = get api_url(project.host, "repos/%s/%s/issues/%d" %
+        [project.owner, project.name, number])
+      if 404 == res.status
+        raise Context::FatalError, "issue #%d not found in %s" % [number, project.name_with_owner]
+      end
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: List issues of a repo. Pull requests, which GitHub also lists
+    # as issues, are left out unless include_pulls is true.
+    #
+    # filters - Hash with any of :state, :labels, :assignee, :milestone,
+    #           :creator, :mentioned, :since, :sort and :direction
+    # limit   - stop after fetching this many issues
+    def issues project, filters = {}, limit = nil, include_pulls = false
+      url = api_url(project.host, "repos/%s/%s/issues" % [project.owner, project.name])
+      select = lambda { |issue| !issue['pull_request'] } unless include_pulls
+      get_all with_query(url, filters), :select => select, :limit => limit
+    end
+
+    # Public: Open a new issue.
+    #
+    # params - Hash with :title and any of :body, :labels, :assignees and
+    #          :milestone (number)
+    # marker - optional string unique to this issue, such as an ID from the
+    #          system that issues are imported from. It's added to the body
+    #          if missing, and if an issue with it already exists, that
+    #          issue is returned instead of opening a duplicate. This costs
+    #          an extra search request, and issues opened within the last
+    #          minute or so may not be found yet.
+    #
+    # Returns parsed data from the new or existing issue.
+    def create_issue project, params, marker = nil
+      if marker
+        query = %(repo:%s type:issue in:body "%s") % [project.name_with_owner, marker.tr('"', ' ')]
+        existing = search_issues(project.host, query).find { |issue| issue['body'].to_s.include? marker }
+        return existing if existing
+        body = params[:body].to_s
+        params = params.merge(:body => body.empty? ? marker : "#{body}\n\n#{marker}") unless body.include? marker
+      end
+
+      res = post api_url(project.host, "repos/%s/%s/issues" % [project.owner, project.name]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Search issues and pull requests using GitHub search syntax,
+    # e.g. "repo:defunkt/hub is:open label:bug".
+    #
+    # Returns matching issues, best matches first.
+    def search_issues host, query, limit = nil
+      get_all with_query(api_url(host, "search/issues"), :q => query), :key => 'items', :limit => limit
+    end
+
+    # Public: Logins of the users that issues of a repo can be assigned to.
+    # GitHub silently ignores assignees that aren't among them.
+    def assignees project
+      get_all(api_url(project.host, "repos/%s/%s/assignees" % [project.owner, project.name])).
+        map { |user| user['login'] }
+    end
+
+    # Public: List open and closed milestones of a repo.
+    def milestones project
+      url = api_url(project.host, "repos/%s/%s/milestones" % [project.owner, project.name])
+      get_all with_query(url, :state => 'all')
+    end
+
+    # Public: List all labels of a repo.
+    def labels project
+      get_all api_url(project.host, "repos/%s/%s/labels" % [project.owner, project.name])
+    end
+
+    # Public: Create a label.
+    #
+    # color - hex color code without the leading "#", e.g. "ff0000"
+    #
+    # Returns parsed data from the new label.
+    def create_label project, name, color, description = nil
+      params = { :name => name, :color => color }
+      params[:description] = description if description
+      res = post api_url(project.host, "repos/%s/%s/labels" % [project.owner, project.name]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Edit a label. Only the fields present in `params` are changed.
+    #
+    # params - Hash with any of :new_name, :color and :description
+    #
+    # Returns parsed data from the updated label.
+    def update_label project, name, params
+      res = patch api_url(project.host, "repos/%s/%s/labels/%s" %
+        [project.owner, project.name, label_path(name)]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Delete a label, removing it from all issues.
+    def delete_label project, name
+      res = delete api_url(project.host, "repos/%s/%s/labels/%s" %
+        [project.owner, project.name, label_path(name)])
+      res.error! unless res.success?
+    end
+
+    # Label names may contain spaces and other characters that need escaping
+    # in a URL path.
+    def label_path name
+      require 'cgi'
+      CGI.escape(name).gsub('+', '%20')
+    end
+
+    # Public: List the comments on an issue or pull request, oldest first.
+    def issue_comments project, number
+      get_all api_url(project.host, "repos/%s/%s/issues/%d/comments" %
+        [project.owner, project.name, number])
+    end
+
+    # Media type required while the Timeline API is in preview.
+    TIMELINE_MEDIA_TYPE = 'application/vnd.github.mockingbird-preview+json'
+
+    # Public: List the timeline of an issue or pull request, oldest first.
+    # Unlike issue events, the timeline includes cross-references from
+    # other issues and commits that mention it.
+    #
+    # Returns a list of events, each with an "event" type such as
+    # "cross-referenced", "referenced", "commented" or "labeled".
+    def timeline project, number
+      get_all(api_url(project.host, "repos/%s/%s/issues/%d/timeline" %
+        [project.owner, project.name, number])) { |req|
+        req['Accept'] = TIMELINE_MEDIA_TYPE
+      }
+    end
+
+    # Public: Comment on an issue or pull request.
+    #
+    # Returns parsed data from the new comment.
+    def create_issue_comment project, number, body
+      res = post api_url(project.host, "repos/%s/%s/issues/%d/comments" %
+        [project.owner, project.name, number]), :body => body
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Edit an issue. Only the fields present in `params` are sent,
+    # so leaving out :labels keeps them as they are while passing
+    # `:labels => []` removes all labels.
+    #
+    # params - Hash with any of :title, :body, :state ("open" or "closed"),
+    #          :labels, :assignees and :milestone (number, or nil to unset it)
+    #
+    # Returns parsed data from the updated issue.
+    def update_issue project, number, params
+      unknown = params.keys.map { |key| key.to_sym } - [:title, :body, :state, :labels, :assignees, :milestone]
+      raise ArgumentError, "unknown issue fields: #{unknown.join(', ')}" if unknown.any?
+
+      res = patch api_url(project.host, "repos/%s/%s/issues/%d" %
+        [project.owner, project.name, number]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Turn an issue into a discussion. The issue is closed and locked
+    # by GitHub in the process.
+    #
+    # category_id - GraphQL node ID of the discussion category
+    #
+    # Returns the URL of the new discussion.
+    def convert_issue_to_discussion project, number, category_id
+      issue = issue_info(project, number)
+      data = graphql project.host, <<-GRAPHQL, 'id' => issue['node_id'], 'categoryId' => category_id
+        mutation($id: ID!, $categoryId: ID!) {
+          convertIssueToDiscussion(input: {issueId: $id, categoryId: $categoryId}) {
+            discussion { url }
+          }
+        }
+      GRAPHQL
+      data['convertIssueToDiscussion']['discussion']['url']
+    end
+
+    # Public: List one page of discussions in a category of a repo, newest
+    # first.
+    #
+    # category_id - GraphQL node ID of the discussion category
+    # first       - size of the page
+    # after       - "endCursor" of the previous page, if any
+    #
+    # Returns a Hash with "nodes" and "pageInfo" ("hasNextPage", "endCursor").
+    def discussions project, category_id, first = 30, after = nil
+      variables = {'owner' => project.owner, 'name' => project.name,
+        'categoryId' => category_id, 'first' => first, 'after' => after}
+      data = graphql project.host, <<-GRAPHQL, variables
+        query($owner: String!, $name: String!, $categoryId: ID, $first: Int!, $after: String) {
+          repository(owner: $owner, name: $name) {
+            discussions(categoryId: $categoryId, first: $first, after: $after,
+                        orderBy: {field: CREATED_AT, direction: DESC}) {
+              nodes { id number title url createdAt author { login } }
+              pageInfo { hasNextPage endCursor }
+            }
+          }
+        }
+      GRAPHQL
+      data['repository']['discussions']
+    end
+
+    # Public: Start a discussion in a category of a repo.
+    #
+    # Returns a Hash with "id", "number" and "url" of the new discussion.
+    def create_discussion project, category_id, title, body
+      repo = repo_info(project)
+      repo.error! unless repo.success?
+      variables = {'repositoryId' => repo.data['node_id'], 'categoryId' => category_id,
+        'title' => title, 'body' => body}
+      data = graphql project.host, <<-GRAPHQL, variables
+        mutation($repositoryId: ID!, $categoryId: ID!, $title: String!, $body: String!) {
+          createDiscussion(input: {repositoryId: $repositoryId, categoryId: $categoryId,
+                                   title: $title, body: $body}) {
+            discussion { id number url }
+          }
+        }
+      GRAPHQL
+      data['createDiscussion']['discussion']
+    end
+
+    # Public: List pull requests of a repo.
+    #
+    # filters - Hash with any of :state ("open", "closed" or "all"), :base,
+    #           :head ("owner:branch"), :sort and :direction
+    # limit   - stop after fetching this many pull requests
+    def pullrequests project, filters = {}, limit = nil
+      url = api_url(project.host, "repos/%s/%s/pulls" % [project.owner, project.name])
+      get_all with_query(url, filters), :limit => limit
+    end
+
+    # Public: Fetch a discussion of a repo by number.
+    #
+    # Returns a Hash with "id", "number", "title", "body", "url", "author"
+    # and "category" of the discussion.
+    def discussion project, number
+      variables = {'owner' => project.owner, 'name' => project.name, 'number' => number.to_i}
+      data = graphql project.host, <<-GRAPHQL, variables
+        query($owner: String!, $name: String!, $number: Int!) {
+          repository(owner: $owner, name: $name) {
+            discussion(number: $number) {
+              id number title body url author { login } category { id name }
+            }
+          }
+        }
+      GRAPHQL
+      unless discussion = data['repository']['discussion']
+        raise Context::FatalError, "discussion #%d not found in %s" % [number, project.name_with_owner]
+      end
+      discussion
+    end
+
+    # Public: Reply to a discussion.
+    #
+    # discussion_id - GraphQL node ID of the discussion
+    #
+    # Returns a Hash with "id" and "url" of the new comment.
+    def create_discussion_comment project, discussion_id, body
+      data = graphql project.host, <<-GRAPHQL, 'id' => discussion_id, 'body' => body
+        mutation($id: ID!, $body: String!) {
+          addDiscussionComment(input: {discussionId: $id, body: $body}) {
+            comment { id url }
+          }
+        }
+      GRAPHQL
+      data['addDiscussionComment']['comment']
+    end
+
+    # Public: Fetch info about a pull request.
+    def pullrequest_info project, pull_id
+      res = get api_url(project.host, "repos/%s/%s/pulls/%d" %
+        [project.owner, project.name, pull_id])
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Fetch a pull request given as a number like "12" or "#12" in
+    # project, as the URL of its page on github.com or an Enterprise host,
+    # or as the name of its head branch in project, optionally prefixed by
+    # "owner:". A branch resolves to its open pull request.
+    def resolve_pullrequest project, ref
+      case ref.to_s
+      when /\A#?(\d+)\z/
+        pullrequest_info project, $1
+      when %r{\Ahttps?://([^/]+)/([^/]+)/([^/]+)/pull/(\d+)}
+        url_project = Context::GithubProject.new(project.local_repo, $2, $3, $1.downcase)
+        pullrequest_info url_project, $4
+      else
+        head = ref.index(':') ? ref : "#{project.owner}:#{ref}"
+        unless pull = pullrequests(project, {:head => head}, 1).first
+          raise Context::FatalError, "no open pull request found for #{head}"
+        end
+        pullrequest_info project, pull['number']
+      end
+    end
+
+    # Media type for fetching a pull request in `git format-patch` format.
+    PATCH_MEDIA_TYPE = 'application/vnd.github.v3.patch'
+
+    # Public: Commits of a pull request as a patch that `git am` can apply.
+    def pullrequest_patch project, pull_id
+      res = get(api_url(project.host, "repos/%s/%s/pulls/%d" %
+        [project.owner, project.name, pull_id])) { |req|
+        req['Accept'] = PATCH_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.body
+    end
+
+    # Public: Merge a pull request.
+    #
+    # options - Hash with any of :merge_method ("merge", "squash" or "rebase"),
+    #           :commit_title, :commit_message and :sha (the merge fails
+    #           unless the head is still at this commit)
+    #
+    # Returns parsed data with "sha" of the merge commit.
+    def merge_pullrequest project, pull_id, options = {}
+      res = put api_url(project.host, "repos/%s/%s/pulls/%d/merge" %
+        [project.owner, project.name, pull_id]), options
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Delete a branch of a repo.
+    def delete_branch project, branch
+      res = delete api_url(project.host, "repos/%s/%s/git/refs/heads/%s" %
+        [project.owner, project.name, branch])
+      res.error! unless res.success?
+    end
+
+    # Public: Merge a pull request, then delete its head branch unless that
+    # lives in a fork (or a fork that no longer exists).
+    #
+    # merge_method - "merge", "squash" or "rebase"
+    #
+    # Returns parsed data with "sha" of the merge commit.
+    def merge_pullrequest_and_delete_branch project, pull_id, merge_method = 'merge'
+      pull = pullrequest_info(project, pull_id)
+      merge = merge_pullrequest(project, pull_id, :merge_method => merge_method)
+
+      head_repo = pull['head']['repo']
+      if head_repo and head_repo['full_name'].downcase == pull['base']['repo']['full_name'].downcase
+        delete_branch(project, pull['head']['ref'])
+      end
+      merge
+    end
+
+    # Public: Sum up the state of many pull requests with one GraphQL query
+    # per 50 pull requests. Each is one of "merged", "closed", "conflicting",
+    # "changes_requested", "review_required", "approved", "mergeable" or
+    # "unknown" (while GitHub is still checking for conflicts).
+    #
+    # Returns a Hash of pull request numbers to states; numbers that don't
+    # exist are left out.
+    def pullrequest_statuses project, numbers
+      statuses = {}
+      numbers.map { |num| num.to_i }.uniq.each_slice(50) do |batch|
+        fields = batch.map { |num|
+          "pr#{num}: pullRequest(number: #{num}) { number state mergeable reviewDecision }"
+        }
+        variables = {'owner' => project.owner, 'name' => project.name}
+        data = graphql project.host, <<-GRAPHQL, variables, true
+          query($owner: String!, $name: String!) {
+            repository(owner: $owner, name: $name) { #{fields.join(' ')} }
+          }
+        GRAPHQL
+        data['repository'].each_value do |pull|
+          next unless pull
+          statuses[pull['number']] =
+            if    'MERGED' == pull['state'] then 'merged'
+            elsif 'CLOSED' == pull['state'] then 'closed'
+            elsif 'CONFLICTING' == pull['mergeable'] then 'conflicting'
+            elsif pull['reviewDecision'] then pull['reviewDecision'].downcase
+            else pull['mergeable'].to_s.downcase
+            end
+        end
+      end
+      statuses
+    end
+
+    # Returns parsed data from the new pull request.
+    def create_pullrequest options
+      project = options.fetch(:project)
+      params = {
+        :base => options.fetch(:base),
+        :head => options.fetch(:head)
+      }
+
+      if options[:issue]
+        params[:issue] = options[:issue]
+      else
+        params[:title] = options[:title] if options[:title]
+        params[:body]  = options[:body]  if options[:body]
+      end
+
+      res = post api_url(project.host, "repos/%s/%s/pulls" %
+        [project.owner, project.name]), params
+
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Open a pull request in base_project from a branch of
+    # head_project, which is usually a fork of it.
+    #
+    # Returns parsed data from the new pull request.
+    def create_pullrequest_from_fork base_project, head_project, head_branch, base_branch, title, body = nil
+      create_pullrequest :project => base_project,
+        :head => "#{head_project.owner}:#{head_branch}", :base => base_branch,
+        :title => title, :body => body
+    end
+
+    # Public: List all review comments on the diff of a pull request. Unlike
+    # `issue_comments`, these are attached to lines of the diff.
+    #
+    # Returns a list of comments with "path", "line", "diff_hunk", "body",
+    # "user" and "in_reply_to_id" (for replies in a thread) among other data.
+    def pullrequest_comments project, pull_id
+      get_all api_url(project.host, "repos/%s/%s/pulls/%d/comments" %
+        [project.owner, project.name, pull_id])
+    end
+
+    # Public: Comment on a line of a file in the diff of a pull request.
+    #
+    # Returns parsed data from the new comment.
+    def create_pullrequest_comment project, pull_id, body, commit_id, path, line
+      params = { :body => body, :commit_id => commit_id, :path => path, :line => line }
+      res = post api_url(project.host, "repos/%s/%s/pulls/%d/comments" %
+        [project.owner, project.name, pull_id]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Reply to a review comment in its thread.
+    #
+    # Returns parsed data from the new comment.
+    def reply_to_pullrequest_comment project, pull_id, comment_id, body
+      raise ArgumentError, "reply to comment #{comment_id} can't be empty" if body.to_s.strip.empty?
+      params = { :body => body, :in_reply_to => comment_id }
+      res = post api_url(project.host, "repos/%s/%s/pulls/%d/comments" %
+        [project.owner, project.name, pull_id]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Mark a review comment thread on a pull request as resolved.
+    #
+    # thread_id - GraphQL node ID of the review thread
+    def resolve_pullrequest_comment_thread project, thread_id
+      graphql project.host, <<-GRAPHQL, 'threadId' => thread_id
+        mutation($threadId: ID!) {
+          resolveReviewThread(input: {threadId: $threadId}) { thread { isResolved } }
+        }
+      GRAPHQL
+    end
+
+    # Public: Have a pull request merged as soon as all required checks
+    # pass. There is no REST equivalent, so this goes through GraphQL.
+    #
+    # pull_id      - number of the pull request, or its GraphQL "node_id"
+    #                to save looking it up
+    # merge_method - "merge", "squash" or "rebase"
+    def enable_auto_merge project, pull_id, merge_method = 'merge'
+      unless %w[merge squash rebase].include?(merge_method.to_s)
+        raise ArgumentError, "invalid merge method: #{merge_method} (use merge, squash or rebase)"
+      end
+      require_enterprise_version project.host, '3.1', 'auto-merge'
+      node_id = pull_id.to_s =~ /\A\d+\z/ ? pullrequest_info(project, pull_id)['node_id'] : pull_id
+      graphql project.host, <<-GRAPHQL, 'id' => node_id, 'method' => merge_method.to_s.upcase
+        mutation($id: ID!, $method: PullRequestMergeMethod) {
+          enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+            clientMutationId
+          }
+        }
+      GRAPHQL
+    end
+
+    # Public: Cancel a previously enabled auto-merge of a pull request.
+    def disable_auto_merge project, pull_id
+      require_enterprise_version project.host, '3.1', 'auto-merge'
+      pull = pullrequest_info(project, pull_id)
+      graphql project.host, <<-GRAPHQL, 'id' => pull['node_id']
+        mutation($id: ID!) {
+          disablePullRequestAutoMerge(input: {pullRequestId: $id}) { clientMutationId }
+        }
+      GRAPHQL
+    end
+
+    # Public: List the OAuth scopes granted to the token used for a host.
+    def token_scopes host
+      res = head api_url(host, "user")
+      res.error! unless res.success?
+      res['X-OAuth-Scopes'].to_s.split(',').map { |scope| scope.strip }
+    end
+
+    def statuses project, sha
+      res = get paginated(api_url(project.host, "repos/%s/%s/statuses/%s" %
+        [project.owner, project.name, sha]))
+
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: List code scanning alerts of a repo, such as CodeQL findings.
+    # Filters that are nil are left out.
+    #
+    # ref      - branch as "refs/heads/<name>", or pull request merge ref
+    # state    - "open", "closed", "dismissed" or "fixed"
+    # severity - "critical", "high", "medium", "low", "warning", "note"
+    #            or "error"
+    #
+    # Returns a list of alerts with "number", "state", "dismissed_reason",
+    # "rule", "tool" and "most_recent_instance" among other data.
+    def code_scanning_alerts project, ref = nil, state = nil, severity = nil
+      url = api_url(project.host, "repos/%s/%s/code-scanning/alerts" % [project.owner, project.name])
+      get_all with_query(url, :ref => ref, :state => state, :severity => severity)
+    end
+
+    # Public: Dismiss a code scanning alert, e.g. as a false positive.
+    #
+    # reason  - "false positive", "won't fix" or "used in tests"
+    # comment - optional explanation shown with the alert
+    #
+    # Returns parsed data from the updated alert.
+    def dismiss_code_scanning_alert project, number, reason, comment = nil
+      params = { :state => 'dismissed', :dismissed_reason => reason }
+      params[:dismissed_comment] = comment if comment
+      res = patch api_url(project.host, "repos/%s/%s/code-scanning/alerts/%d" %
+        [project.owner, project.name, number]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: List Dependabot alerts about vulnerable dependencies of a repo.
+    # Filters that are nil are left out.
+    #
+    # state     - "open", "dismissed", "fixed" or "auto_dismissed"
+    # severity  - "low", "medium", "high" or "critical"
+    # ecosystem - package ecosystem, e.g. "npm", "pip" or "rubygems"
+    #
+    # Returns a list of alerts with "number", "state", "security_advisory",
+    # "auto_dismissed_at" and "fixed_at" among other data.
+    def dependabot_alerts project, state = nil, severity = nil, ecosystem = nil
+      url = api_url(project.host, "repos/%s/%s/dependabot/alerts" % [project.owner, project.name])
+      get_all with_query(url, :state => state, :severity => severity, :ecosystem => ecosystem)
+    end
+
+    # Media types required while these security settings are in preview.
+    VULNERABILITY_ALERTS_MEDIA_TYPE = 'application/vnd.github.dorian-preview+json'
+    SECURITY_FIXES_MEDIA_TYPE = 'application/vnd.github.london-preview+json'
+
+    # Public: Turn on alerts about vulnerable dependencies of a repo.
+    def enable_vulnerability_alerts project
+      res = put(api_url(project.host, "repos/%s/%s/vulnerability-alerts" %
+        [project.owner, project.name])) { |req|
+        req['Accept'] = VULNERABILITY_ALERTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+    end
+
+    # Public: Turn on pull requests that update vulnerable dependencies of a
+    # repo. Vulnerability alerts need to be enabled first.
+    def enable_automated_security_fixes project
+      res = put(api_url(project.host, "repos/%s/%s/automated-security-fixes" %
+        [project.owner, project.name])) { |req|
+        req['Accept'] = SECURITY_FIXES_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+    end
+
+    # Public: Dismiss a Dependabot alert, e.g. after accepting the risk.
+    #
+    # reason  - "fix_started", "inaccurate", "no_bandwidth", "not_used" or
+    #           "tolerable_risk"
+    # comment - optional explanation shown with the alert
+    #
+    # Returns parsed data from the updated alert.
+    def dismiss_dependabot_alert project, number, reason, comment = nil
+      params = { :state => 'dismissed', :dismissed_reason => reason }
+      params[:dismissed_comment] = comment if comment
+      res = patch api_url(project.host, "repos/%s/%s/dependabot/alerts/%d" %
+        [project.owner, project.name, number]), params
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: List secret scanning alerts about credentials committed to a
+    # repo. Filters that are nil are left out.
+    #
+    # state       - "open" or "resolved"
+    # secret_type - comma-separated secret types, e.g. "github_personal_access_token"
+    #
+    # Returns a list of alerts with "number", "state", "secret_type",
+    # "resolution" and "html_url" among other data.
+    def secret_scanning_alerts project, state = nil, secret_type = nil
+      url = api_url(project.host, "repos/%s/%s/secret-scanning/alerts" % [project.owner, project.name])
+      get_all with_query(url, :state => state, :secret_type => secret_type)
+    end
+
+    # Reasons that a secret scanning alert can be resolved with.
+    SECRET_SCANNING_RESOLUTIONS = %w[false_positive wont_fix revoked used_in_tests]
+
+    # Public: Resolve a secret scanning alert.
+    #
+    # resolution - one of SECRET_SCANNING_RESOLUTIONS
+    #
+    # Returns parsed data from the updated alert.
+    def resolve_secret_scanning_alert project, number, resolution
+      unless SECRET_SCANNING_RESOLUTIONS.include? resolution.to_s
+        raise ArgumentError, "invalid resolution: #{resolution} (use one of: #{SECRET_SCANNING_RESOLUTIONS.join(', ')})"
+      end
+      res = patch api_url(project.host, "repos/%s/%s/secret-scanning/alerts/%d" %
+        [project.owner, project.name, number]), :state => 'resolved', :resolution => resolution
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Latest status of a commit reported under a specific context,
+    # such as "continuous-integration/travis-ci", or nil if there is none.
+    def status_for_context project, sha, context
+      statuses(project, sha).find { |status| status['context'] == context }
+    end
+
+    # Public: List GitHub Actions workflow runs of a repo, newest first.
+    # Filters that are nil are left out.
+    #
+    # branch - name of the branch the runs were triggered for
+    # event  - triggering event, e.g. "push" or "pull_request"
+    # status - "queued", "in_progress", "completed", or a conclusion such
+    #          as "success" or "failure"
+    #
+    # Options:
+    # - actor: login of the user who triggered the runs
+    # - created: date range such as ">=2020-01-01" or "2020-01-01..2020-01-31"
+    # - limit: stop after fetching this many runs
+    #
+    # Returns a list of runs with "id", "name", "status", "conclusion" and
+    # "head_sha" among other data.
+    def workflow_runs project, branch = nil, event = nil, status = nil, options = {}
+      url = api_url(project.host, "repos/%s/%s/actions/runs" % [project.owner, project.name])
+      query = { :branch => branch, :event => event, :status => status,
+                :actor => options[:actor], :created => options[:created] }
+      get_all with_query(url, query), :key => 'workflow_runs', :limit => options[:limit]
     end
 
-    # Fake exception type for net/http exception handling.
-    # Necessary because net/http may or may not be loaded at the time.
-    module Exceptions
-      def self.===(exception)
-        exception.class.ancestors.map {|a| a.to_s }.include? 'Net::HTTPExceptions'
+    # Public: List the GitHub Actions workflows of a repo.
+    #
+    # Returns a list of workflows with "id", "name", "path" (such as
+    # ".github/workflows/deploy.yml") and "state" among other data.
+    def workflows project
+      get_all api_url(project.host, "repos/%s/%s/actions/workflows" %
+        [project.owner, project.name]), :key => 'workflows'
+    end
+
+    # Public: Trigger a workflow that has a `workflow_dispatch` event.
+    #
+    # workflow - workflow ID or file name, e.g. "deploy.yml"
+    # ref      - branch or tag to run the workflow on
+    # inputs   - Hash of inputs declared by the workflow
+    def dispatch_workflow project, workflow, ref, inputs = {}
+      raise ArgumentError, "a ref is required to dispatch a workflow" if ref.to_s.empty?
+      res = post api_url(project.host, "repos/%s/%s/actions/workflows/%s/dispatches" %
+        [project.owner, project.name, workflow]), :ref => ref, :inputs => inputs
+      res.error! unless res.success?
+    end
+
+    # Public: Send a `repository_dispatch` event to trigger workflows that
+    # listen for it.
+    #
+    # event_type - name of the event, at most 100 characters
+    # payload    - Hash passed to workflows as `client_payload`
+    def create_repository_dispatch project, event_type, payload = {}
+      if event_type.to_s.empty?
+        raise ArgumentError, "an event type is required for a repository dispatch"
+      elsif event_type.length > 100
+        raise ArgumentError, "event type can't be longer than 100 characters"
       end
+      res = post api_url(project.host, "repos/%s/%s/dispatches" % [project.owner, project.name]),
+        :event_type => event_type, :client_payload => payload
+      res.error! unless res.success?
     end
 
-    def api_host host
-      host = host.downcase
-      'github.com' == host ? 'api.github.com' : host
+    # Public: Record a deployment of a ref to an environment.
+    #
+    # params - Hash with any other deployment fields, such as :description,
+    #          :payload, :auto_merge or :required_contexts
+    #
+    # Returns parsed data from the new deployment.
+    def create_deployment project, ref, environment, params = {}
+      res = post api_url(project.host, "repos/%s/%s/deployments" % [project.owner, project.name]),
+        params.merge(:ref => ref, :environment => environment)
+      res.error! unless res.success?
+      res.data
     end
 
-    # Public: Fetch data for a specific repo.
-    def repo_info project
-      get "https://%s/repos/%s/%s" %
-        [api_host(project.host), project.owner, project.name]
+    # States that a deployment status can have.
+    DEPLOYMENT_STATES = %w[error failure inactive in_progress queued pending success]
+
+    # Public: Update the state of a deployment.
+    #
+    # state  - one of DEPLOYMENT_STATES
+    # params - Hash with any of :description, :environment_url and :log_url
+    #
+    # Returns parsed data from the new deployment status.
+    def create_deployment_status project, deployment_id, state, params = {}
+      unless DEPLOYMENT_STATES.include? state.to_s
+        raise ArgumentError, "invalid deployment state: #{state} (use one of: #{DEPLOYMENT_STATES.join(', ')})"
+      end
+      res = post api_url(project.host, "repos/%s/%s/deployments/%d/statuses" %
+        [project.owner, project.name, deployment_id]), params.merge(:state => state)
+      res.error! unless res.success?
+      res.data
     end
 
-    # Public: Determine whether a specific repo exists.
-    def repo_exists? project
-      repo_info(project).success?
+    # Public: List the deployment environments of a repo.
+    def environments project
+      get_all api_url(project.host, "repos/%s/%s/environments" % [project.owner, project.name]),
+        :key => 'environments'
     end
 
-    # Public: Fork the specified repo.
-    def fork_repo project
-      res = post "https://%s/repos/%s/%s/forks" %
-        [api_host(project.host), project.owner, project.name]
+    # Public: Create an environment, or update its settings if it exists.
+    #
+    # params - Hash with any of :wait_timer (minutes), :reviewers (list of
+    #          Hashes with :type "User" or "Team" and :id) and
+    #          :deployment_branch_policy
+    #
+    # Returns parsed data from the environment.
+    def create_environment project, name, params = {}
+      res = put api_url(project.host, "repos/%s/%s/environments/%s" %
+        [project.owner, project.name, label_path(name)]), params
       res.error! unless res.success?
+      res.data
     end
 
-    # Public: Create a new project.
-    def create_repo project, options = {}
-      is_org = project.owner.downcase != config.username(api_host(project.host)).downcase
-      params = { :name => project.name, :private => !!options[:private] }
-      params[:description] = options[:description] if options[:description]
-      params[:homepage]    = options[:homepage]    if options[:homepage]
+    # Public: Delete an environment along with its secrets and protection
+    # rules.
+    def delete_environment project, name
+      res = delete api_url(project.host, "repos/%s/%s/environments/%s" %
+        [project.owner, project.name, label_path(name)])
+      res.error! unless res.success?
+    end
 
-      if is_org
-        res = post "https://%s/orgs/%s/repos" % [api_host(project.host), project.owner], params
-      else
-        res = post "https://%s/user/repos" % api_host(project.host), params
+    # Public: The public key that Actions secrets of a repo must be encrypted
+    # with before they're created.
+    #
+    # Returns a Hash with "key_id" and the Base64-encoded "key".
+    def repo_public_key project
+      res = get api_url(project.host, "repos/%s/%s/actions/secrets/public-key" %
+        [project.owner, project.name])
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: List the names and update times of the Actions secrets of a
+    # repo. Their values can't be read back.
+    def repo_secrets project
+      get_all api_url(project.host, "repos/%s/%s/actions/secrets" % [project.owner, project.name]),
+        :key => 'secrets'
+    end
+
+    # Public: Create or update an Actions secret of a repo.
+    #
+    # encrypted_value - value encrypted with `encrypt_secret` for the key
+    #                   from `repo_public_key`
+    # key_id          - "key_id" of that public key
+    def create_repo_secret project, name, encrypted_value, key_id
+      res = put api_url(project.host, "repos/%s/%s/actions/secrets/%s" %
+        [project.owner, project.name, name]), :encrypted_value => encrypted_value, :key_id => key_id
+      res.error! unless res.success?
+    end
+
+    # Public: Delete an Actions secret of a repo.
+    def delete_repo_secret project, name
+      res = delete api_url(project.host, "repos/%s/%s/actions/secrets/%s" %
+        [project.owner, project.name, name])
+      res.error! unless res.success?
+    end
+
+    # Public: List the Actions secrets of an organization, each with its
+    # "visibility": "all", "private" or "selected" repos.
+    def org_secrets host, org
+      get_all api_url(host, "orgs/%s/actions/secrets" % org), :key => 'secrets'
+    end
+
+    # Public: The public key that Actions secrets of an organization must be
+    # encrypted with.
+    #
+    # Returns a Hash with "key_id" and the Base64-encoded "key".
+    def org_public_key host, org
+      res = get api_url(host, "orgs/%s/actions/secrets/public-key" % org)
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Create or update an Actions secret shared by repos of an
+    # organization.
+    #
+    # encrypted_value   - value encrypted with `encrypt_secret` for the key
+    #                     from `org_public_key`
+    # visibility        - "all", "private" or "selected"
+    # selected_repo_ids - IDs of the repos that can use the secret when
+    #                     visibility is "selected"
+    def create_org_secret host, org, name, encrypted_value, key_id, visibility, selected_repo_ids = nil
+      params = { :encrypted_value => encrypted_value, :key_id => key_id, :visibility => visibility }
+      params[:selected_repository_ids] = selected_repo_ids if 'selected' == visibility
+      res = put api_url(host, "orgs/%s/actions/secrets/%s" % [org, name]), params
+      res.error! unless res.success?
+    end
+
+    # Public: Delete an Actions secret of an organization.
+    def delete_org_secret host, org, name
+      res = delete api_url(host, "orgs/%s/actions/secrets/%s" % [org, name])
+      res.error! unless res.success?
+    end
+
+    # Public: Encrypt a secret value with a libsodium sealed box for the
+    # public key of a repo, environment or organization. Requires the
+    # rbnacl gem, which is only loaded when a secret is encrypted.
+    #
+    # public_key - Hash with the Base64-encoded "key"
+    #
+    # Returns the Base64-encoded `encrypted_value` for creating the secret.
+    def encrypt_secret public_key, value
+      begin
+        require 'rbnacl'
+      rescue LoadError
+        raise Context::FatalError, "encrypting secrets requires the rbnacl gem (gem install rbnacl)"
       end
+      key = public_key['key'].unpack('m').first
+      encrypted = RbNaCl::Boxes::Sealed.from_public_key(key).encrypt(value)
+      [encrypted].pack('m').gsub("\n", '')
+    end
+
+    # Public: List the names and update times of the secrets of an
+    # environment. These are separate from the secrets of the repo.
+    def environment_secrets project, environment
+      get_all api_url(project.host, "repos/%s/%s/environments/%s/secrets" %
+        [project.owner, project.name, label_path(environment)]), :key => 'secrets'
+    end
+
+    # Public: The public key that environment secrets must be encrypted with.
+    #
+    # Returns a Hash with "key_id" and the Base64-encoded "key".
+    def environment_public_key project, environment
+      res = get api_url(project.host, "repos/%s/%s/environments/%s/secrets/public-key" %
+        [project.owner, project.name, label_path(environment)])
       res.error! unless res.success?
       res.data
     end
 
-    # Public: Fetch info about a pull request.
-    def pullrequest_info project, pull_id
-      res = get "https://%s/repos/%s/%s/pulls/%d" %
-        [api_host(project.host), project.owner, project.name, pull_id]
+    # Public: Create or update a secret of an environment. The value must be
+    # encrypted beforehand with a libsodium sealed box using the key from
+    # `environment_public_key`, which differs from the key of the repo.
+    #
+    # encrypted_value - Base64-encoded encrypted value
+    # key_id          - "key_id" of the public key it was encrypted with
+    def create_environment_secret project, environment, name, encrypted_value, key_id
+      res = put api_url(project.host, "repos/%s/%s/environments/%s/secrets/%s" %
+        [project.owner, project.name, label_path(environment), name]),
+        :encrypted_value => encrypted_value, :key_id => key_id
+      res.error! unless res.success?
+    end
+
+    # Public: Fetch a single workflow run.
+    #
+    # Returns parsed data with "id", "name", "status", "conclusion",
+    # "head_sha", "html_url", "created_at" and "updated_at" among other data.
+    def workflow_run project, run_id
+      res = get api_url(project.host, "repos/%s/%s/actions/runs/%d" %
+        [project.owner, project.name, run_id])
       res.error! unless res.success?
       res.data
     end
 
-    # Returns parsed data from the new pull request.
-    def create_pullrequest options
-      project = options.fetch(:project)
-      params = {
-        :base => options.fetch(:base),
-        :head => options.fetch(:head)
-      }
+    # Public: Re-run all jobs of a workflow run.
+    def rerun_workflow_run project, run_id
+      res = post api_url(project.host, "repos/%s/%s/actions/runs/%d/rerun" %
+        [project.owner, project.name, run_id])
+      res.error! unless res.success?
+    end
 
-      if options[:issue]
-        params[:issue] = options[:issue]
-      else
-        params[:title] = options[:title] if options[:title]
-        params[:body]  = options[:body]  if options[:body]
+    # Public: Cancel a workflow run that is queued or in progress.
+    def cancel_workflow_run project, run_id
+      res = post api_url(project.host, "repos/%s/%s/actions/runs/%d/cancel" %
+        [project.owner, project.name, run_id])
+      res.error! unless res.success?
+    end
+
+    # Public: List the jobs of a workflow run.
+    #
+    # filter - "latest" for jobs of the most recent attempt, or "all" to
+    #          include jobs of earlier attempts
+    #
+    # Returns a list of jobs with "id", "name", "status", "conclusion" and
+    # "steps". Each step has "name", "number", "status" and "conclusion".
+    def workflow_run_jobs project, run_id, filter = 'latest'
+      unless %w[latest all].include?(filter)
+        raise ArgumentError, "job filter must be \"latest\" or \"all\", got #{filter.inspect}"
       end
+      url = api_url(project.host, "repos/%s/%s/actions/runs/%d/jobs" %
+        [project.owner, project.name, run_id])
+      get_all with_query(url, :filter => filter), :key => 'jobs'
+    end
 
-      res = post "https://%s/repos/%s/%s/pulls" %
-        [api_host(project.host), project.owner, project.name], params
+    # Public: Download the plain text log of a workflow job into `io`. The
+    # API redirects to a short-lived URL that the log is streamed from.
+    def download_job_logs project, job_id, io
+      download api_url(project.host, "repos/%s/%s/actions/jobs/%d/logs" %
+        [project.owner, project.name, job_id]), io
+    end
 
+    # Public: Combined status of a commit, where "state" sums up the latest
+    # status of every context.
+    def combined_status project, sha
+      res = get api_url(project.host, "repos/%s/%s/commits/%s/status" %
+        [project.owner, project.name, sha])
       res.error! unless res.success?
       res.data
     end
 
-    def statuses project, sha
-      res = get "https://%s/repos/%s/%s/statuses/%s" %
-        [api_host(project.host), project.owner, project.name, sha]
+    # Public: List check runs, such as those of GitHub Actions, reported on
+    # a commit. Each has "name", "status", "conclusion", "started_at",
+    # "completed_at", "details_url" and "html_url" among other data.
+    # Enterprise instances older than 2.15 have no check runs, so none are
+    # listed for them.
+    def check_runs project, sha
+      return [] unless enterprise_version_at_least?(project.host, '2.15')
+      get_all api_url(project.host, "repos/%s/%s/commits/%s/check-runs" %
+        [project.owner, project.name, sha]), :key => 'check_runs'
+    end
+
+    # Public: Poll the combined status of a commit until it's no longer
+    # "pending". Polling uses conditional requests, so responses for an
+    # unchanged status don't count against the rate limit.
+    #
+    # timeout - seconds to wait at most
+    # poll    - seconds between requests
+    #
+    # Returns the final combined status data.
+    def wait_for_ci_status project, sha, timeout, poll = 10
+      url = api_url(project.host, "repos/%s/%s/commits/%s/status" %
+        [project.owner, project.name, sha])
+      deadline = Time.now + timeout
+      etag = status = nil
+
+      loop do
+        res = get(url) { |req| req['If-None-Match'] = etag if etag }
+        unless 304 == res.status
+          res.error! unless res.success?
+          etag, status = res['ETag'], res.data
+        end
+        return status unless 'pending' == status['state']
+
+        if Time.now + poll > deadline
+          raise Context::FatalError, "timed out waiting for CI status of #{sha[0, 7]}"
+        end
+        sleep poll
+      end
+    end
+
+    # Media type required while the Projects API is in preview.
+    PROJECTS_MEDIA_TYPE = 'application/vnd.github.inertia-preview+json'
+
+    # Public: List the classic project boards of a repo.
+    def project_boards project
+      res = get(paginated(api_url(project.host, "repos/%s/%s/projects" %
+        [project.owner, project.name]))) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Create a classic project board for a repo.
+    #
+    # Returns parsed data from the new board.
+    def create_project_board project, name, body = nil
+      params = { :name => name }
+      params[:body] = body if body
+
+      res = post(api_url(project.host, "repos/%s/%s/projects" %
+        [project.owner, project.name]), params) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: List the columns of a classic project board.
+    def project_columns host, board_id
+      res = get(paginated(api_url(host, "projects/%d/columns" % board_id))) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Add a column to a classic project board.
+    #
+    # Returns parsed data from the new column.
+    def create_project_column host, board_id, name
+      res = post(api_url(host, "projects/%d/columns" % board_id), :name => name) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Add an existing issue or pull request to a project column.
+    #
+    # content_type - "Issue" or "PullRequest"
+    #
+    # Returns parsed data from the new card.
+    def create_project_card host, column_id, content_id, content_type
+      params = { :content_id => content_id, :content_type => content_type }
+      res = post(api_url(host, "projects/columns/%d/cards" % column_id), params) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Add a plain-text note to a project column.
+    #
+    # Returns parsed data from the new card.
+    def create_project_card_note host, column_id, note
+      res = post(api_url(host, "projects/columns/%d/cards" % column_id), :note => note) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+      res.data
+    end
+
+    # Public: Move a project card within its column or to another column.
+    #
+    # position  - "top", "bottom" or "after:<card_id>"
+    # column_id - ID of the destination column (default: the current one)
+    def move_project_card host, card_id, position, column_id = nil
+      params = { :position => position }
+      params[:column_id] = column_id if column_id
+
+      res = post(api_url(host, "projects/columns/cards/%d/moves" % card_id), params) { |req|
+        req['Accept'] = PROJECTS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+    end
+
+    # Media type required while the Reactions API is in preview.
+    REACTIONS_MEDIA_TYPE = 'application/vnd.github.squirrel-girl-preview+json'
 
+    # Emoji that can be used as reactions.
+    REACTIONS = %w[+1 -1 laugh confused heart hooray rocket eyes]
+
+    # Public: List the reactions on an issue, pull request or comment.
+    #
+    # content_type - "issues" (also for pull requests), "issues/comments",
+    #                "pulls/comments" or "comments" (commit comments)
+    def reactions project, content_type, content_id
+      res = get(paginated(api_url(project.host, "repos/%s/%s/%s/%d/reactions" %
+        [project.owner, project.name, content_type, content_id]))) { |req|
+        req['Accept'] = REACTIONS_MEDIA_TYPE
+      }
       res.error! unless res.success?
       res.data
     end
 
+    # Public: React to an issue, pull request or comment with one of
+    # REACTIONS. See `reactions` for content_type values.
+    def add_reaction project, content_type, content_id, reaction
+      unless REACTIONS.include? reaction
+        raise ArgumentError, "unknown reaction: #{reaction}"
+      end
+      res = post(api_url(project.host, "repos/%s/%s/%s/%d/reactions" %
+        [project.owner, project.name, content_type, content_id]), :content => reaction) { |req|
+        req['Accept'] = REACTIONS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+    end
+
+    # Public: Count the reactions on an issue or pull request per content
+    # type, e.g. {"+1" => 12, "heart" => 3}.
+    def issue_reaction_summary project, number
+      res = get(api_url(project.host, "repos/%s/%s/issues/%d" %
+        [project.owner, project.name, number])) { |req|
+        req['Accept'] = REACTIONS_MEDIA_TYPE
+      }
+      res.error! unless res.success?
+
+      summary = {}
+      (res.data['reactions'] || {}).each do |content, count|
+        next if %w[url total_count].include?(content)
+        summary[content] = count if count > 0
+      end
+      summary
+    end
+
     # Methods for performing HTTP requests
     #
     # Requires access to a `config` object that implements:
@@ -134,6 +1683,7 @@ module Hub
         def error_message?() data? and data['errors'] || data['message'] end
         def error_message() error_sentences || data['message'] end
         def success?() Net::HTTPSuccess === self end
+        def next_page_url() self['Link'].to_s =~ /<([^>]+)>;\s*rel="next"/ and $1 end
         def error_sentences
           data['errors'].map do |err|
             case err['code']
@@ -153,8 +1703,28 @@ module Hub
         perform_request url, :Get, &block
       end
 
-      def post url, params = nil
-        perform_request url, :Post do |req|
+      def head url, &block
+        perform_request url, :Head, &block
+      end
+
+      def post url, params = nil, &block
+        perform_request_with_body url, :Post, params, &block
+      end
+
+      def patch url, params = nil, &block
+        perform_request_with_body url, :Patch, params, &block
+      end
+
+      def put url, params = nil, &block
+        perform_request_with_body url, :Put, params, &block
+      end
+
+      def delete url, &block
+        perform_request url, :Delete, &block
+      end
+
+      def perform_request_with_body url, type, params
+        perform_request url, type do |req|
           if params
             req.body = JSON.dump params
             req['Content-Type'] = 'application/json;charset=utf-8'
@@ -189,18 +1759,102 @@ module Hub
         apply_authentication(req, url)
         yield req if block_given?
 
-        begin
-          res = http.start { http.request(req) }
-          res.extend ResponseMethods
-          return res
-        rescue SocketError => err
-          raise Context::FatalError, "error with #{type.to_s.upcase} #{url} (#{err.message})"
+        start_request http, req, url
+      end
+
+      def start_request http, req, url, &block
+        res = http.start { http.request(req, &block) }
+        res.extend ResponseMethods
+        res
+      rescue SocketError, SystemCallError, Timeout::Error, OpenSSL::SSL::SSLError => err
+        error = Context::FatalError.new(network_error_message(url, err))
+        error.original_error = err
+        raise error
+      end
+
+      # Downloads a file that the API serves by redirecting elsewhere, like
+      # archives on codeload.github.com or release assets on
+      # objects.githubusercontent.com, and writes its contents to `io`.
+      #
+      # Net::HTTP doesn't follow redirects, so each one is requested here
+      # over a fresh connection from create_connection in order for the
+      # proxy settings to apply to the CDN host as well. Credentials are
+      # only ever sent to the API host.
+      #
+      # Yields the number of bytes written so far and the total size, if
+      # known, as the download progresses.
+      def download url, io, redirects = 5, &progress
+        res = get(url) { |req| req['Accept'] = 'application/octet-stream' }
+        streamed = false
+        while Net::HTTPRedirection === res
+          if (redirects -= 1) < 0
+            raise Context::FatalError, "too many redirects downloading #{url}"
+          end
+          url = URI.join(url.to_s, res['Location'])
+          req = Net::HTTP::Get.new url.request_uri
+          req['User-Agent'] = "Hub #{Hub::VERSION}"
+          http = configure_connection(req, url) do |host_url|
+            create_connection host_url
+          end
+          res = start_request(http, req, url) { |response|
+            if Net::HTTPSuccess === response
+              streamed = true
+              stream_body response, io, &progress
+            end
+          }
+        end
+        res.error! unless res.success?
+        unless streamed
+          io.write res.body
+          yield res.body.size, res.body.size if block_given?
+        end
+        res
+      end
+
+      def stream_body res, io
+        total = res['Content-Length'] && res['Content-Length'].to_i
+        written = 0
+        res.read_body do |chunk|
+          io.write chunk
+          written += byte_size(chunk)
+          yield written, total if block_given?
+        end
+      end
+
+      # Turns low-level connection errors into a short explanation that
+      # mentions the host rather than the full request URL. The original
+      # error is shown as well when HUB_VERBOSE is set.
+      def network_error_message url, err
+        case err
+        when SocketError
+          "could not resolve host #{url.host} -- are you offline?"
+        when Errno::ECONNREFUSED
+          "connection to #{url.host} was refused"
+        when Timeout::Error, Errno::ETIMEDOUT
+          "connection to #{url.host} timed out"
+        when OpenSSL::SSL::SSLError
+          if err.message =~ /certificate verify failed/
+            "TLS certificate for #{url.host} is not trusted (signed by unknown authority); " +
+              "point SSL_CERT_FILE to a CA bundle that includes its issuer"
+          else
+            "TLS connection to #{url.host} failed (#{err.message})"
+          end
+        else
+          "error connecting to #{url.host} (#{err.message})"
         end
       end
 
+      # Paths of URLs that lack the path prefix of the API base URL, such as
+      # "/api/v3" on Enterprise, get it added. URLs that came from the API
+      # itself (pagination links, upload URLs) already have the right prefix
+      # and are left alone, and so are uploads hosts of Enterprise instances
+      # with subdomain isolation, which serve uploads without any prefix.
       def request_uri url
         str = url.request_uri
-        str = '/api/v3' << str if url.host != 'api.github.com'
+        prefix = config.api_path_prefix(url.host)
+        if !prefix.empty? and str.index(prefix + '/') != 0 and str.index('/api/') != 0
+          str = prefix + str
+        end
         str
       end
 
@@ -257,7 +1911,7 @@ module Hub
           }
           if refresh
             # get current user info user to persist correctly capitalized login name
-            res = get "https://#{url.host}/user"
+            res = get "#{url.scheme}://#{url.host}/user"
             res.error! unless res.success?
             config.update_username(url.host, user, res.data['login'])
           end
@@ -267,7 +1921,9 @@ module Hub
 
       def obtain_oauth_token host, user, two_factor_code = nil
         # first try to fetch existing authorization
-        res = get "https://#{user}@#{host}/authorizations" do |req|
+        scheme = URI.parse(config.api_base_url(host)).scheme
+        auth_url = "#{scheme}://#{user}@#{host}/authorizations"
+        res = get auth_url do |req|
           req['X-GitHub-OTP'] = two_factor_code if two_factor_code
         end
         unless res.success?
@@ -283,7 +1939,7 @@ module Hub
           found['token']
         else
           # create a new authorization
-          res = post "https://#{user}@#{host}/authorizations",
+          res = post auth_url,
             :scopes => %w[repo], :note => 'hub', :note_url => oauth_app_url
           res.error! unless res.success?
           res.data['token']
@@ -330,6 +1986,29 @@ module Hub
         end
       end
 
+      # Name of the host entry that lists `host` among its "aliases", or
+      # whose "api_url" or "uploads_host" is on `host`, or `host` itself if
+      # no entry does.
+      def canonical_host host
+        @data.keys.find { |name|
+          @data[name].any? { |entry|
+            Array(entry['aliases']).include?(host) or
+              entry['uploads_host'] == host or
+              (entry['api_url'] and URI.parse(entry['api_url']).host == host)
+          }
+        } || host
+      end
+
+      def host_value host, key
+        entry = @data.fetch(host, []).first and entry[key.to_s]
+      end
+
+      def set_host_value host, key, value
+        entry = get(host).first or return
+        entry[key.to_s] = value
+        save
+      end
+
       def entry_for_user host, username
         entries = get(host)
         entries.find {|e| e['user'] == username } or
@@ -358,17 +2037,36 @@ module Hub
 
       def normalize_host host
         host = host.downcase
-        'api.github.com' == host ? 'github.com' : host
+        host = 'github.com' if %w[api.github.com ssh.github.com].include? host
+        @data.canonical_host host
       end
 
+      # Asks for the username the first time a host is used. For Enterprise
+      # hosts, also asks for the API base URL and stores the answer.
       def username host
         return ENV['GITHUB_USER'] unless ENV['GITHUB_USER'].to_s.empty?
         host = normalize_host host
-        @data.fetch_user host do
+        prompted = false
+        user = @data.fetch_user host do
           if block_given? then yield
-          else prompt "#{host} username"
+          else
+            prompted = true
+            prompt "#{host} username"
           end
         end
+        if prompted and user and 'github.com' != host
+          default = api_base_url(host)
+          answer = prompt("#{host} API URL [#{default}]").strip
+          @data.set_host_value host, :api_url, answer.empty? ? default : answer.chomp('/')
+        end
+        user
+      end
+
+      # Whether the entry for a host has an OAuth token but no username, as
+      # when the config was written by hand.
+      def token_without_username? host
+        entry = @data.get(normalize_host(host)).first
+        !!entry && entry['user'].to_s.empty? && !entry['oauth_token'].to_s.empty?
       end
 
       def update_username host, old_username, new_username
@@ -396,6 +2094,45 @@ module Hub
         @data.fetch_value normalize_host(host), user, :oauth_token, &block
       end
 
+      # Whether an OAuth token is stored for the user, so that API requests
+      # can be made without prompting for credentials.
+      def oauth_token? host, user
+        !@data.entry_for_user(normalize_host(host), user)['oauth_token'].to_s.empty?
+      end
+
+      # Base URL of the API of a host: "api_url" from the host's config entry
+      # if set, "https://api.github.com" for github.com, and
+      # "https://HOST/api/v3" for Enterprise hosts otherwise. Enterprise
+      # test instances without TLS can set "protocol: http" instead of a
+      # full "api_url". Aliases share the entry of their canonical host, but
+      # without a stored "api_url" they keep talking to their own hostname.
+      def api_base_url host
+        entry_host = normalize_host host
+        if url = @data.host_value(entry_host, :api_url)
+          url.chomp('/')
+        elsif 'github.com' == entry_host
+          'https://api.github.com'
+        else
+          "#{@data.host_value(entry_host, :protocol) || 'https'}://#{host.downcase}/api/v3"
+        end
+      end
+
+      # Host that Enterprise instances with subdomain isolation serve
+      # release asset uploads from, set as "uploads_host" in the host's
+      # config entry.
+      def uploads_host host
+        @data.host_value(normalize_host(host), :uploads_host)
+      end
+
+      # Path that requests to an API host are prefixed with, e.g. "/api/v3".
+      # Uploads hosts, including ones named "uploads.*" that aren't
+      # configured, take no prefix.
+      def api_path_prefix api_host
+        api_host = api_host.downcase
+        return '' if api_host.index('uploads.') == 0 or uploads_host(api_host) == api_host
+        URI.parse(api_base_url(api_host)).path.chomp('/')
+      end
+
       def prompt what
         print "#{what}: "
         $stdin.gets.chomp
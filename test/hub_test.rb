@@ -71,6 +71,7 @@ class HubTest < Test::Unit::TestCase
     Hub::Commands.instance_variable_set :@git_reader, @git_reader
     Hub::Commands.instance_variable_set :@local_repo, nil
     Hub::Commands.instance_variable_set :@api_client, nil
+    Hub::Context::LocalRepo.host_override = nil
 
     FileUtils.rm_rf ENV['HUB_CONFIG']
 
@@ -87,6 +88,7 @@ class HubTest < Test::Unit::TestCase
       'config --get --bool hub.http-clone' => 'false',
       'config --get hub.protocol' => nil,
       'config --get-all hub.host' => nil,
+      'config --get hub.browser' => nil,
       'rev-parse -q --git-dir' => '.git'
   end
 
@@ -106,7 +108,8 @@ class HubTest < Test::Unit::TestCase
 
   def test_cherry_pick_url_with_remote_add
     url = 'https://github.com/xoebus/hub/commit/a319d88'
-    assert_commands "git remote add -f xoebus git://github.com/xoebus/hub.git",
+    assert_commands "git fetch git://github.com/xoebus/hub.git +refs/heads/*:refs/remotes/xoebus/*",
+                    "git remote add xoebus git://github.com/xoebus/hub.git",
                     "git cherry-pick a319d88",
                     "cherry-pick #{url}"
   end
@@ -130,27 +133,47 @@ class HubTest < Test::Unit::TestCase
   end
 
   def test_cherry_pick_github_notation_with_remote_add
-    assert_commands "git remote add -f xoebus git://github.com/xoebus/hub.git",
+    assert_commands "git fetch git://github.com/xoebus/hub.git +refs/heads/*:refs/remotes/xoebus/*",
+                    "git remote add xoebus git://github.com/xoebus/hub.git",
                     "git cherry-pick a319d88",
                     "cherry-pick xoebus@a319d88"
   end
 
+  def test_cherry_pick_github_colon_notation
+    assert_commands "git fetch mislav", "git cherry-pick 368af20", "cherry-pick mislav:368af20"
+  end
+
   def test_am_untouched
     assert_forwarded "am some.patch"
   end
 
   def test_am_pull_request
+    stub_pullrequest_patch 55
     with_tmpdir('/tmp/') do
-      assert_commands "curl -#LA 'hub #{Hub::Version}' https://github.com/defunkt/hub/pull/55.patch -o /tmp/55.patch",
-                      "git am --signoff /tmp/55.patch -p2",
+      assert_commands "git am --signoff /tmp/55.patch -p2",
                       "am --signoff https://github.com/defunkt/hub/pull/55#comment_123 -p2"
+      assert_equal "From 1234abc\nSubject: [PATCH] Fix\n", File.read('/tmp/55.patch')
 
       cmd = Hub("am https://github.com/defunkt/hub/pull/55/files").command
-      assert_includes '/pull/55.patch', cmd
+      assert_includes '/tmp/55.patch', cmd
+    end
+  end
+
+  def test_am_enterprise_pull_request
+    stub_request(:get, "https://git.my.org/api/v3/repos/defunkt/hub/pulls/55").
+      with(:headers => { 'Accept' => 'application/vnd.github.v3.patch' }).
+      to_return(:body => "From 1234abc\n")
+    stub_hub_host('git.my.org')
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user' => 'tpw', 'oauth_token' => 'OTOKEN'}]
+    end
+    with_tmpdir('/tmp/') do
+      assert_commands "git am /tmp/55.patch", "am https://git.my.org/defunkt/hub/pull/55"
     end
   end
 
   def test_am_no_tmpdir
+    stub_pullrequest_patch 55
     with_tmpdir(nil) do
       cmd = Hub("am https://github.com/defunkt/hub/pull/55").command
       assert_includes '/tmp/55.patch', cmd
@@ -182,13 +205,13 @@ class HubTest < Test::Unit::TestCase
   end
 
   def test_apply_pull_request
+    stub_pullrequest_patch 55
     with_tmpdir('/tmp/') do
-      assert_commands "curl -#LA 'hub #{Hub::Version}' https://github.com/defunkt/hub/pull/55.patch -o /tmp/55.patch",
-                      "git apply /tmp/55.patch -p2",
+      assert_commands "git apply /tmp/55.patch -p2",
                       "apply https://github.com/defunkt/hub/pull/55 -p2"
 
       cmd = Hub("apply https://github.com/defunkt/hub/pull/55/files").command
-      assert_includes '/pull/55.patch', cmd
+      assert_includes '/tmp/55.patch', cmd
     end
   end
 
@@ -218,6 +241,32 @@ class HubTest < Test::Unit::TestCase
     assert_commands "git init", "git remote add origin git@github.com:tpw/hub.git", "init -g"
   end
 
+  def test_init_with_token_but_no_username
+    stub_no_remotes
+    stub_no_git_repo
+    edit_hub_config do |data|
+      data['github.com'] = [{'oauth_token' => 'OTOKEN'}]
+    end
+    stub_request(:get, "https://api.github.com/user").
+      with(:headers => { 'Authorization' => 'token OTOKEN' }).
+      to_return(:body => '{"login":"mislav"}')
+
+    assert_commands "git init", "git remote add origin git@github.com:mislav/hub.git", "init -g"
+    assert_equal 'mislav', YAML.load(File.read(ENV['HUB_CONFIG']))['github.com'].first['user']
+  end
+
+  def test_init_https_protocol
+    stub_no_remotes
+    stub_no_git_repo
+    stub_https_is_preferred
+    assert_commands "git init", "git remote add origin https://github.com/tpw/hub.git", "init -g"
+  end
+
+  def test_submodule_add_private_with_branch
+    assert_commands "git submodule add --branch v2 git@github.com:mojombo/grit.git vendor/grit",
+                    "submodule add -p --branch v2 mojombo/grit vendor/grit"
+  end
+
   def test_init_enterprise
     stub_no_remotes
     stub_no_git_repo
@@ -230,22 +279,108 @@ class HubTest < Test::Unit::TestCase
     end
   end
 
+  def test_init_host_flag
+    stub_no_remotes
+    stub_no_git_repo
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname'}]
+    end
+
+    assert_commands "git init", "git remote add origin git@git.my.org:myfiname/hub.git", "--host git.my.org init -g"
+    assert_commands "git init", "git remote add origin git@git.my.org:myfiname/hub.git", "--host=git.my.org init -g"
+  end
+
+  def test_init_host_flag_beats_host_env
+    stub_no_remotes
+    stub_no_git_repo
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname'}]
+    end
+
+    with_host_env('other.my.org') do
+      assert_commands "git init", "git remote add origin git@git.my.org:myfiname/hub.git", "--host git.my.org init -g"
+    end
+  end
+
+  def test_host_precedence_without_remotes
+    stub_no_remotes
+    stub_no_git_repo
+    edit_hub_config do |data|
+      %w[other.my.org third.my.org].each { |host| data[host] = [{'user'=>'myfiname', 'oauth_token'=>'FITOKEN'}] }
+    end
+    { 'https://api.github.com/user' => 'github.com',
+      'https://other.my.org/api/v3/user' => 'other.my.org',
+      'https://third.my.org/api/v3/user' => 'third.my.org',
+    }.each do |url, host|
+      stub_request(:get, url).to_return(:body => %({"host":"#{host}"}),
+        :headers => {'Content-Type' => 'application/json'})
+    end
+
+    with_host_env('') do
+      assert_equal %({"host":"github.com"}\n), hub("api user")
+    end
+    with_host_env('other.my.org') do
+      # $GITHUB_HOST beats github.com
+      assert_equal %({"host":"other.my.org"}\n), hub("api user")
+      # `--host` beats $GITHUB_HOST
+      assert_equal %({"host":"third.my.org"}\n), hub("--host third.my.org api user")
+    end
+  end
+
+  def test_host_env_keeps_host_of_remote
+    stub_repo_url('git@git.my.org:defunkt/hub.git')
+    stub_hub_host(['git.my.org', 'other.my.org'])
+    edit_hub_config do |data|
+      %w[git.my.org other.my.org].each { |host| data[host] = [{'user'=>'myfiname'}] }
+    end
+
+    with_host_env('other.my.org') do
+      assert_command "clone defunkt/tilt", "git clone git@git.my.org:defunkt/tilt.git"
+      assert_command "--host other.my.org clone defunkt/tilt", "git clone git@git.my.org:defunkt/tilt.git"
+    end
+  end
+
+  def test_host_defaults_to_github
+    stub_no_remotes
+    with_host_env('') do
+      assert_equal 'github.com', Hub::Context::LocalRepo.default_host
+    end
+  end
+
+  def test_empty_host_flag
+    assert_equal "Error: the `--host` flag requires a host name\n", hub("--host= browse")
+    assert_equal "Error: the `--host` flag requires a host name\n", hub("--host")
+  end
+
+  def test_clone_host_from_remote
+    stub_repo_url('git@git.my.org:defunkt/hub.git')
+    stub_hub_host('git.my.org')
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname'}]
+    end
+
+    assert_command "clone defunkt/tilt", "git clone git@git.my.org:defunkt/tilt.git"
+  end
+
   def test_push_untouched
     assert_forwarded "push"
   end
 
   def test_push_two
+    stub_remotes 'origin', 'staging'
     assert_commands "git push origin cool-feature", "git push staging cool-feature",
                     "push origin,staging cool-feature"
   end
 
   def test_push_current_branch
+    stub_remotes 'origin', 'staging'
     stub_branch('refs/heads/cool-feature')
     assert_commands "git push origin cool-feature", "git push staging cool-feature",
                     "push origin,staging"
   end
 
   def test_push_more
+    stub_remotes 'origin', 'staging', 'qa'
     assert_commands "git push origin cool-feature",
                     "git push staging cool-feature",
                     "git push qa cool-feature",
@@ -253,11 +388,81 @@ class HubTest < Test::Unit::TestCase
   end
 
   def test_push_multiple_refs
+    stub_remotes 'origin', 'staging'
     assert_commands "git push origin master new-feature",
                     "git push staging master new-feature",
                     "push origin,staging master new-feature"
   end
 
+  def test_push_with_flags
+    stub_remotes 'origin', 'staging'
+    assert_commands "git push --force-with-lease --tags origin cool-feature",
+                    "git push --force-with-lease --tags staging cool-feature",
+                    "push --force-with-lease origin,staging --tags cool-feature"
+  end
+
+  def test_push_unknown_remote
+    stub_remotes 'origin', 'staging'
+    assert_equal "Aborted: no such remote: stagign, qa\n",
+      hub("push origin,stagign,qa cool-feature")
+  end
+
+  def test_push_flags_with_values
+    stub_remotes 'origin', 'staging'
+    assert_commands "git push -o ci.skip --repo origin origin cool-feature",
+                    "git push -o ci.skip --repo origin staging cool-feature",
+                    "push -o ci.skip --repo origin origin,staging cool-feature"
+  end
+
+  def test_push_continue_on_error
+    stub_remotes 'origin', 'staging', 'qa'
+    cmds = Hub("push --continue-on-error origin,staging,qa cool-feature").args.commands
+    assert_equal 4, cmds.size
+    assert cmds.all? { |cmd| cmd.respond_to?(:call) }
+
+    # a git that fails to push to "staging"
+    fake_git = Tempfile.open 'git'
+    fake_git.puts "#!/bin/sh", 'test "$2" != staging'
+    fake_git.close
+    File.chmod 0755, fake_git.path
+
+    # reap earlier `hub` subprocesses so that the wait below gets this one
+    Process.waitall
+    output = hub("push --continue-on-error origin,staging,qa cool-feature") do
+      ENV['GIT'] = fake_git.path
+    end
+    Process.wait
+    assert_equal "Pushed to: origin, qa\nFailed to push to: staging\n", output
+    assert_equal 1, $?.exitstatus
+  end
+
+  def test_pullrequest_missing_message_file
+    stub_branch('refs/heads/feature')
+
+    assert_match(/\AError: can't read missing.txt \(No such file or directory.*\)\n\z/,
+      hub("pull-request -F missing.txt"))
+  end
+
+  def test_issue_comment_missing_message_file
+    assert_match(/\AError: can't read missing.txt \(No such file or directory.*\)\n\z/,
+      hub("issue comment 12 -F missing.txt"))
+  end
+
+  def test_issue_create_missing_message_file
+    assert_match(/\AError: can't read missing.txt \(No such file or directory.*\)\n\z/,
+      hub("issue create -F missing.txt"))
+  end
+
+  def test_release_create_missing_message_file
+    assert_match(/\AError: can't read missing.txt \(No such file or directory.*\)\n\z/,
+      hub("release create v1.0 -F missing.txt"))
+  end
+
+  def test_release_edit_missing_message_file
+    assert_match(/\AError: can't read missing.txt \(No such file or directory.*\)\n\z/,
+      hub("release edit v1.0 -F missing.txt"))
+  end
+
   def test_pullrequest_from_branch_tracking_local
     stub_branch('refs/heads/feature')
     stub_tracking('feature', 'refs/heads/master')
@@ -288,6 +493,556 @@ class HubTest < Test::Unit::TestCase
     assert_output expected, "pull-request -m hereyougo -f"
   end
 
+  def test_pullrequest_enterprise_http_protocol
+    stub_hub_host('git.my.org')
+    stub_repo_url('git@git.my.org:defunkt/hub.git')
+    stub_branch('refs/heads/feature')
+    stub_tracking_nothing('feature')
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname', 'oauth_token' => 'FITOKEN', 'protocol' => 'http'}]
+    end
+
+    stub_request(:post, "http://git.my.org/api/v3/repos/defunkt/hub/pulls").
+      with(:body => {'base' => "master", 'head' => "myfiname:feature", 'title' => "hereyougo" }).
+      to_return(:body => mock_pullreq_response(1, 'defunkt/hub', 'git.my.org'))
+
+    expected = "https://git.my.org/defunkt/hub/pull/1\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_enterprise_host_alias
+    stub_hub_host(['git.my.org', 'git'])
+    stub_repo_url('git@git:defunkt/hub.git')
+    stub_branch('refs/heads/feature')
+    stub_tracking_nothing('feature')
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname', 'oauth_token' => 'FITOKEN', 'aliases' => ['git']}]
+    end
+
+    stub_request(:post, "https://git/api/v3/repos/defunkt/hub/pulls").
+      with(:headers => {'Authorization' => 'token FITOKEN'},
+           :body => {'base' => "master", 'head' => "myfiname:feature", 'title' => "hereyougo" }).
+      to_return(:body => mock_pullreq_response(1, 'defunkt/hub', 'git'))
+
+    expected = "https://git/defunkt/hub/pull/1\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_unresolvable_host
+    stub_branch('refs/heads/feature')
+    stub_tracking('feature', 'refs/heads/master')
+
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/pulls").
+      to_raise(SocketError.new("getaddrinfo: nodename nor servname provided, or not known"))
+
+    expected = "fatal: could not resolve host api.github.com -- are you offline?\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_connection_refused
+    stub_branch('refs/heads/feature')
+    stub_tracking('feature', 'refs/heads/master')
+
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/pulls").
+      to_raise(Errno::ECONNREFUSED)
+
+    expected = "fatal: connection to api.github.com was refused\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_enterprise_timeout
+    stub_hub_host('git.my.org')
+    stub_repo_url('git@git.my.org:defunkt/hub.git')
+    stub_branch('refs/heads/feature')
+    stub_tracking_nothing('feature')
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname', 'oauth_token' => 'FITOKEN'}]
+    end
+
+    stub_request(:post, "https://git.my.org/api/v3/repos/defunkt/hub/pulls").to_timeout
+
+    expected = "fatal: connection to git.my.org timed out\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_tls_failure
+    stub_branch('refs/heads/feature')
+    stub_tracking('feature', 'refs/heads/master')
+
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/pulls").
+      to_raise(OpenSSL::SSL::SSLError.new("SSL_connect returned=1 errno=0 state=error: " +
+        "certificate verify failed (unable to get local issuer certificate)"))
+
+    expected = "fatal: TLS certificate for api.github.com is not trusted (signed by unknown authority); " +
+      "point SSL_CERT_FILE to a CA bundle that includes its issuer\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_tls_handshake_failure
+    stub_branch('refs/heads/feature')
+    stub_tracking('feature', 'refs/heads/master')
+
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/pulls").
+      to_raise(OpenSSL::SSL::SSLError.new("wrong version number"))
+
+    expected = "fatal: TLS connection to api.github.com failed (wrong version number)\n"
+    assert_output expected, "pull-request -m hereyougo -f"
+  end
+
+  def test_pullrequest_network_error_verbose
+    stub_branch('refs/heads/feature')
+    stub_tracking('feature', 'refs/heads/master')
+
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/pulls").
+      to_raise(Errno::ECONNREFUSED)
+
+    output = hub("pull-request -m hereyougo -f") { ENV['HUB_VERBOSE'] = '1' }
+    assert_match(/\AErrno::ECONNREFUSED: Connection refused.*\nfatal: connection to api.github.com was refused\n\z/,
+      output)
+  end
+
+  def test_api_request_uri
+    api = Hub::Commands.send(:api_client)
+    {
+      'https://api.github.com/repos/defunkt/hub/pulls?page=2' =>
+        '/repos/defunkt/hub/pulls?page=2',
+      'https://uploads.github.com/repos/defunkt/hub/releases/1/assets?name=hub.tgz' =>
+        '/repos/defunkt/hub/releases/1/assets?name=hub.tgz',
+      'https://git.my.org/repos/defunkt/hub/issues?state=open' =>
+        '/api/v3/repos/defunkt/hub/issues?state=open',
+      'https://git.my.org/api/v3/repos/defunkt/hub/issues?page=3' =>
+        '/api/v3/repos/defunkt/hub/issues?page=3',
+      'https://git.my.org/api/uploads/repos/defunkt/hub/releases/1/assets?name=hub.tgz' =>
+        '/api/uploads/repos/defunkt/hub/releases/1/assets?name=hub.tgz',
+      'https://uploads.git.my.org/repos/defunkt/hub/releases/1/assets?name=hub.tgz' =>
+        '/repos/defunkt/hub/releases/1/assets?name=hub.tgz',
+    }.each do |url, expected|
+      assert_equal expected, api.request_uri(URI.parse(url)), url
+    end
+  end
+
+  def test_api_base_url
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user' => 'myfiname', 'oauth_token' => 'FITOKEN', 'protocol' => 'http',
+                             'aliases' => ['git']}]
+      data['ghe.example.com'] = [{'user' => 'myfiname', 'oauth_token' => 'FITOKEN',
+                                  'api_url' => 'https://api.ghe.example.com/',
+                                  'uploads_host' => 'media.ghe.example.com',
+                                  'aliases' => ['ghe']}]
+    end
+    api = Hub::Commands.send(:api_client)
+
+    # aliases use the entry of their canonical host but keep their hostname
+    # unless an "api_url" is stored
+    assert_equal 'http://git/api/v3/user', api.api_url('git', 'user')
+    assert_equal 'https://api.ghe.example.com/user', api.api_url('ghe', 'user')
+
+    assert_equal 'https://api.github.com/user', api.api_url('github.com', 'user')
+    assert_equal 'http://git.my.org/api/v3/user', api.api_url('git.my.org', 'user')
+    assert_equal 'https://api.ghe.example.com/user', api.api_url('ghe.example.com', 'user')
+    assert_equal 'https://other.my.org/api/v3/user', api.api_url('other.my.org', 'user')
+    assert_equal 'api.ghe.example.com', api.api_host('ghe.example.com')
+    assert_equal 'http://git.my.org/api/graphql', api.graphql_url('git.my.org')
+    assert_equal 'https://api.ghe.example.com/graphql', api.graphql_url('ghe.example.com')
+
+    {
+      'http://git.my.org/user' => '/api/v3/user',
+      'https://api.ghe.example.com/repos/defunkt/hub' => '/repos/defunkt/hub',
+      'https://media.ghe.example.com/repos/defunkt/hub/releases/1/assets?name=hub.tgz' =>
+        '/repos/defunkt/hub/releases/1/assets?name=hub.tgz',
+    }.each do |url, expected|
+      assert_equal expected, api.request_uri(URI.parse(url)), url
+    end
+  end
+
+  def test_api_uploads_host
+    edit_hub_config do |data|
+      data['ghe.example.com'] = [{'user' => 'myfiname', 'oauth_token' => 'FITOKEN',
+                                  'uploads_host' => 'media.ghe.example.com'}]
+    end
+    upload = stub_request(:post, "https://media.ghe.example.com/repos/defunkt/hub/releases/1/assets?name=hub.tgz").
+      with(:headers => {'Authorization' => 'token FITOKEN'}).
+      to_return(:status => 201, :body => '{"id":3}', :headers => {'Content-Type' => 'application/json'})
+
+    api = Hub::Commands.send(:api_client)
+    res = api.post("https://media.ghe.example.com/repos/defunkt/hub/releases/1/assets?name=hub.tgz")
+    assert_equal 3, res.data['id']
+    assert_requested upload
+  end
+
+  def test_api_enterprise_host_prompt
+    @prompt_stubs << lambda { |what| assert_equal 'git.my.org username', what; 'myfiname' }
+    @prompt_stubs << lambda { |what| assert_equal 'git.my.org API URL [https://git.my.org/api/v3]', what; 'http://git.my.org/api/v3/' }
+    edit_hub_config { |data| data.delete('git.my.org') }
+
+    api = Hub::Commands.send(:api_client)
+    assert_equal 'myfiname', api.config.username('git.my.org')
+    assert_equal 'myfiname', api.config.username('git.my.org')
+    assert_equal 'http://git.my.org/api/v3/user', api.api_url('git.my.org', 'user')
+    assert @prompt_stubs.empty?
+
+    config = YAML.load(File.read(ENV['HUB_CONFIG']))
+    assert_equal [{'user' => 'myfiname', 'api_url' => 'http://git.my.org/api/v3'}], config['git.my.org']
+  end
+
+  def test_api_version_at_least
+    assert Hub::GitHubAPI.version_at_least?('2.19.3', '2.19')
+    assert Hub::GitHubAPI.version_at_least?('2.19', '2.9')
+    assert Hub::GitHubAPI.version_at_least?('3.0.0', '2.22')
+    assert Hub::GitHubAPI.version_at_least?('3.1', '3.1.0')
+    assert !Hub::GitHubAPI.version_at_least?('2.9', '2.19')
+    assert !Hub::GitHubAPI.version_at_least?('2.22.5', '3.0')
+    assert !Hub::GitHubAPI.version_at_least?('3.0', '3.0.1')
+  end
+
+  def test_api_enterprise_version
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user'=>'myfiname', 'oauth_token' => 'FITOKEN'}]
+    end
+    stub_request(:get, "https://git.my.org/api/v3/meta").
+      to_return(:body => '{}', :headers => {'X-GitHub-Enterprise-Version' => '2.16.4'}).
+      times(1).then.to_raise("requested twice")
+
+    api = Hub::Commands.send(:api_client)
+    assert_nil api.enterprise_version('github.com')
+    assert_equal '2.16.4', api.enterprise_version('git.my.org')
+    api.require_enterprise_version('git.my.org', '2.16', 'topics')
+
+    err = assert_raise(Hub::Context::FatalError) {
+      api.require_enterprise_version('git.my.org', '2.17', 'draft pull requests')
+    }
+    assert_equal "your GitHub Enterprise 2.16.4 does not support draft pull requests", err.message
+
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'git.my.org')
+    # check runs are skipped rather than requested
+    assert_equal [], api.check_runs(project, 'abc123')
+    err = assert_raise(Hub::Context::FatalError) { api.enable_auto_merge(project, 12) }
+    assert_equal "your GitHub Enterprise 2.16.4 does not support auto-merge", err.message
+    err = assert_raise(Hub::Context::FatalError) { api.generate_release_notes(project, 'v1.0') }
+    assert_equal "your GitHub Enterprise 2.16.4 does not support generated release notes", err.message
+  end
+
+  def test_api_per_page
+    url = 'https://api.github.com/repos/defunkt/hub/projects'
+    api = Hub::GitHubAPI.new(nil, :app_url => 'http://hub.github.com/')
+    assert_equal url, api.paginated(url)
+
+    api = Hub::GitHubAPI.new(nil, :app_url => 'http://hub.github.com/', :per_page => 50)
+    assert_equal "#{url}?per_page=50", api.paginated(url)
+    assert_equal "#{url}?state=open&per_page=50", api.paginated("#{url}?state=open")
+
+    api = Hub::GitHubAPI.new(nil, :app_url => 'http://hub.github.com/', :per_page => 500)
+    assert_equal "#{url}?per_page=100", api.paginated(url)
+  end
+
+  def test_api_workflow_runs
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    url = "https://api.github.com/repos/defunkt/hub/actions/runs"
+    stub_request(:get, "#{url}?branch=feature%2Fci&status=completed").
+      to_return(:body => '{"total_count":3,"workflow_runs":[{"id":3},{"id":2}]}',
+                :headers => {'Content-Type' => 'application/json',
+                             'Link' => %(<#{url}?page=2>; rel="next", <#{url}?page=2>; rel="last")})
+    stub_request(:get, "#{url}?page=2").
+      to_return(:body => '{"total_count":3,"workflow_runs":[{"id":1}]}',
+                :headers => {'Content-Type' => 'application/json'})
+
+    api = Hub::Commands.send(:api_client)
+    runs = api.workflow_runs(project, 'feature/ci', nil, 'completed')
+    assert_equal [3, 2, 1], runs.map { |run| run['id'] }
+  end
+
+  def test_api_workflow_runs_by_actor_and_date
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    url = "https://api.github.com/repos/defunkt/hub/actions/runs"
+    stub_request(:get, "#{url}?actor=mislav&created=%3E%3D2020-01-01").
+      to_return(:body => '{"total_count":3,"workflow_runs":[{"id":3},{"id":2}]}',
+                :headers => {'Content-Type' => 'application/json',
+                             'Link' => %(<#{url}?page=2>; rel="next")})
+
+    api = Hub::Commands.send(:api_client)
+    runs = api.workflow_runs(project, nil, nil, nil, :actor => 'mislav', :created => '>=2020-01-01', :limit => 1)
+    assert_equal [3], runs.map { |run| run['id'] }
+  end
+
+  def test_api_workflows_and_dispatch
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/actions/workflows").
+      to_return(:body => '{"total_count":2,"workflows":[{"id":161335,"path":".github/workflows/ci.yml"},' +
+                         '{"id":269289,"path":".github/workflows/deploy.yml"}]}',
+                :headers => {'Content-Type' => 'application/json'})
+    by_id = stub_request(:post, "https://api.github.com/repos/defunkt/hub/actions/workflows/269289/dispatches").
+      with(:body => { 'ref' => 'main', 'inputs' => { 'version' => '1.2.0' } })
+    by_file = stub_request(:post, "https://api.github.com/repos/defunkt/hub/actions/workflows/deploy.yml/dispatches").
+      with(:body => { 'ref' => 'v1.2.0', 'inputs' => {} })
+
+    api = Hub::Commands.send(:api_client)
+    workflows = api.workflows(project)
+    assert_equal [161335, 269289], workflows.map { |workflow| workflow['id'] }
+    api.dispatch_workflow(project, workflows.last['id'], 'main', 'version' => '1.2.0')
+    api.dispatch_workflow(project, 'deploy.yml', 'v1.2.0')
+    assert_requested by_id
+    assert_requested by_file
+    assert_raises(ArgumentError) { api.dispatch_workflow(project, 'deploy.yml', '') }
+  end
+
+  def test_api_cancel_workflow_run
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    cancel = stub_request(:post, "https://api.github.com/repos/defunkt/hub/actions/runs/30433642/cancel").
+      to_return(:status => 202, :body => '{}')
+
+    api = Hub::Commands.send(:api_client)
+    api.cancel_workflow_run(project, 30433642)
+    assert_requested cancel
+  end
+
+  def test_api_workflow_run_jobs
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/actions/runs/30433642/jobs?filter=all").
+      to_return(:body => '{"total_count":1,"jobs":[{"id":399444496,"conclusion":"failure",' +
+                         '"steps":[{"name":"Run tests","number":3,"conclusion":"failure"}]}]}',
+                :headers => {'Content-Type' => 'application/json'})
+
+    api = Hub::Commands.send(:api_client)
+    jobs = api.workflow_run_jobs(project, 30433642, 'all')
+    assert_equal [399444496], jobs.map { |job| job['id'] }
+    assert_equal 'Run tests', jobs.first['steps'].first['name']
+    assert_raises(ArgumentError) { api.workflow_run_jobs(project, 30433642, 'failed') }
+  end
+
+  def test_api_download_job_logs
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/actions/jobs/399444496/logs").
+      to_return(:status => 302, :headers => {'Location' => 'https://pipelines.actions.githubusercontent.com/logs/399444496?sig=abc'})
+    stub_request(:get, "https://pipelines.actions.githubusercontent.com/logs/399444496?sig=abc").
+      with { |req| !req.headers.key?('Authorization') }.
+      to_return(:body => "2020-01-05T00:00:00Z Run tests\n2020-01-05T00:00:01Z Error: 1 failure\n")
+
+    api = Hub::Commands.send(:api_client)
+    io = StringIO.new
+    api.download_job_logs(project, 399444496, io)
+    assert_equal "Error: 1 failure", io.string.split("\n").last.split(' ', 2).last
+  end
+
+  def test_api_traffic_without_push_access
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/traffic/views?per=week").
+      to_return(:body => '{"count":14850,"uniques":3782,"views":[{"timestamp":"2020-01-05T00:00:00Z","count":440,"uniques":143}]}',
+                :headers => {'Content-Type' => 'application/json'})
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/traffic/clones").
+      to_return(:status => 403, :body => '{"message":"Must have push access to repository"}',
+                :headers => {'Content-Type' => 'application/json'})
+
+    api = Hub::Commands.send(:api_client)
+    views = api.traffic_views(project, 'week')
+    assert_equal [14850, 3782], [views['count'], views['uniques']]
+    assert_equal 440, views['views'].first['count']
+
+    err = assert_raise(Hub::Context::FatalError) { api.traffic_clones(project) }
+    assert_equal "push access to defunkt/hub is required to view its traffic", err.message
+  end
+
+  def test_api_traffic_rate_limited
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/traffic/views").
+      to_return(:status => 403, :body => '{"message":"API rate limit exceeded for user ID 1."}',
+                :headers => {'Content-Type' => 'application/json', 'X-RateLimit-Remaining' => '0'})
+
+    api = Hub::Commands.send(:api_client)
+    err = begin
+      api.traffic_views(project)
+      nil
+    rescue Hub::GitHubAPI::Exceptions
+      $!
+    end
+    assert_equal 'API rate limit exceeded for user ID 1.', err.response.data['message']
+  end
+
+  def test_api_encrypt_secret
+    begin
+      require 'rbnacl'
+    rescue LoadError
+      omit "the rbnacl gem is needed to encrypt secrets"
+    end
+    private_key = RbNaCl::PrivateKey.generate
+    public_key = { 'key_id' => '568250167242549743',
+                   'key' => [private_key.public_key.to_bytes].pack('m').gsub("\n", '') }
+
+    api = Hub::Commands.send(:api_client)
+    encrypted = api.encrypt_secret(public_key, 's3cr3t')
+    box = RbNaCl::Boxes::Sealed.from_private_key(private_key)
+    assert_equal 's3cr3t', box.decrypt(encrypted.unpack('m').first)
+  end
+
+  def test_api_download_archive_through_proxy
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/tarball/v1.0").
+      to_return(:status => 302, :headers => {'Location' => 'https://codeload.github.com/defunkt/hub/legacy.tar.gz/v1.0'})
+    stub_request(:get, "https://codeload.github.com/defunkt/hub/legacy.tar.gz/v1.0").
+      with { |req| !req.headers.key?('Authorization') }.
+      to_return(:body => 'TARBALL')
+
+    api = Hub::Commands.send(:api_client)
+    proxies = []
+    api.extend Module.new {
+      define_method(:create_connection) { |url|
+        http = super(url)
+        proxies << [url.host, http.proxy_address]
+        http
+      }
+    }
+    io = StringIO.new
+    with_proxy_env('proxy.example.com:3128') do
+      api.download_archive(project, 'v1.0', io)
+    end
+    assert_equal 'TARBALL', io.string
+    assert_equal [['api.github.com', 'proxy.example.com'], ['codeload.github.com', 'proxy.example.com']], proxies
+  end
+
+  def test_api_create_pullrequest_from_fork
+    base = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    head = Hub::Context::GithubProject.new(nil, 'mislav', 'hub', 'github.com')
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/pulls").
+      with(:body => { 'base' => "master", 'head' => "mislav:feature", 'title' => "Add feature" }).
+      to_return(:body => mock_pullreq_response(12))
+
+    api = Hub::Commands.send(:api_client)
+    pull = api.create_pullrequest_from_fork(base, head, 'feature', 'master', 'Add feature')
+    assert_equal 'https://github.com/defunkt/hub/pull/12', pull['html_url']
+  end
+
+  def test_api_merge_pullrequest_and_delete_branch
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls/12").
+      to_return(:body => '{"head":{"ref":"feature","repo":{"full_name":"defunkt/hub"}},' +
+                         '"base":{"ref":"master","repo":{"full_name":"defunkt/hub"}}}')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls/13").
+      to_return(:body => '{"head":{"ref":"feature","repo":{"full_name":"mislav/hub"}},' +
+                         '"base":{"ref":"master","repo":{"full_name":"defunkt/hub"}}}')
+    stub_request(:put, %r{https://api.github.com/repos/defunkt/hub/pulls/1[23]/merge}).
+      with(:body => { 'merge_method' => "squash" }).
+      to_return(:body => '{"sha":"abc123","merged":true}')
+    delete_ref = stub_request(:delete, "https://api.github.com/repos/defunkt/hub/git/refs/heads/feature").
+      to_return(:status => 204)
+
+    api = Hub::Commands.send(:api_client)
+    assert_equal 'abc123', api.merge_pullrequest_and_delete_branch(project, 12, 'squash')['sha']
+    assert_requested delete_ref, :times => 1
+    api.merge_pullrequest_and_delete_branch(project, 13, 'squash')
+    assert_requested delete_ref, :times => 1
+  end
+
+  def test_api_default_branch
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    repo = stub_request(:get, "https://api.github.com/repos/defunkt/hub").
+      to_return(:body => '{"full_name":"defunkt/hub","default_branch":"main"}')
+
+    api = Hub::Commands.send(:api_client)
+    assert_equal 'main', api.default_branch(project)
+    assert_equal 'main', api.default_branch(project)
+    assert_requested repo, :times => 1
+  end
+
+  def test_api_create_issue_with_marker
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    search = "https://api.github.com/search/issues?q=repo%3Adefunkt%2Fhub+type%3Aissue+in%3Abody+%22import-42%22"
+    stub_request(:get, search).
+      to_return(:body => '{"total_count":1,"items":[{"number":7,"body":"Imported\n\nimport-42"}]}')
+    stub_request(:get, search.sub('42', '43')).
+      to_return(:body => '{"total_count":0,"items":[]}')
+    stub_request(:post, "https://api.github.com/repos/defunkt/hub/issues").
+      with(:body => { 'title' => "Imported", 'body' => "From Jira\n\nimport-43" }).
+      to_return(:body => '{"number":8}')
+
+    api = Hub::Commands.send(:api_client)
+    assert_equal 7, api.create_issue(project, { :title => 'Imported' }, 'import-42')['number']
+    assert_equal 8, api.create_issue(project, { :title => 'Imported', :body => 'From Jira' }, 'import-43')['number']
+  end
+
+  def test_api_pullrequest_statuses
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:post, "https://api.github.com/graphql").
+      with { |req| req.body.include?('pr1: pullRequest(number: 1)') && req.body.include?('pr5: pullRequest') }.
+      to_return(:headers => {'Content-Type' => 'application/json'}, :body => Hub::JSON.generate(
+        :data => { :repository => {
+          :pr1 => { :number => 1, :state => 'MERGED', :mergeable => 'UNKNOWN', :reviewDecision => nil },
+          :pr2 => { :number => 2, :state => 'OPEN', :mergeable => 'CONFLICTING', :reviewDecision => 'APPROVED' },
+          :pr3 => { :number => 3, :state => 'OPEN', :mergeable => 'MERGEABLE', :reviewDecision => 'CHANGES_REQUESTED' },
+          :pr4 => { :number => 4, :state => 'OPEN', :mergeable => 'MERGEABLE', :reviewDecision => nil },
+          :pr5 => nil
+        } },
+        :errors => [{ :type => 'NOT_FOUND', :message => 'Could not resolve to a PullRequest with the number of 5.' }]
+      ))
+
+    api = Hub::Commands.send(:api_client)
+    expected = { 1 => 'merged', 2 => 'conflicting', 3 => 'changes_requested', 4 => 'mergeable' }
+    assert_equal expected, api.pullrequest_statuses(project, [1, 2, 3, 4, 5, 2])
+  end
+
+  def test_api_resolve_pullrequest
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    edit_hub_config do |data|
+      data['git.my.org'] = [{'user' => 'myfiname', 'oauth_token' => 'FITOKEN'}]
+    end
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls/12").
+      to_return(:body => '{"number":12}', :headers => {'Content-Type' => 'application/json'})
+    stub_request(:get, "https://git.my.org/api/v3/repos/mislav/hub/pulls/3").
+      to_return(:body => '{"number":3}', :headers => {'Content-Type' => 'application/json'})
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls?head=mislav%3Afeature").
+      to_return(:body => '[{"number":12}]', :headers => {'Content-Type' => 'application/json'})
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls?head=defunkt%3Atypos").
+      to_return(:body => '[]', :headers => {'Content-Type' => 'application/json'})
+
+    api = Hub::Commands.send(:api_client)
+    assert_equal 12, api.resolve_pullrequest(project, '12')['number']
+    assert_equal 12, api.resolve_pullrequest(project, '#12')['number']
+    assert_equal 3, api.resolve_pullrequest(project, 'https://git.my.org/mislav/hub/pull/3/files')['number']
+    assert_equal 12, api.resolve_pullrequest(project, 'mislav:feature')['number']
+
+    err = assert_raise(Hub::Context::FatalError) { api.resolve_pullrequest(project, 'typos') }
+    assert_equal "no open pull request found for defunkt:typos", err.message
+  end
+
+  def test_api_enable_auto_merge
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls/12").
+      to_return(:body => '{"number":12,"node_id":"PR_kwDOAAABBB4"}')
+    mutation = stub_request(:post, "https://api.github.com/graphql").
+      with { |req|
+        data = Hub::JSON.parse(req.body)
+        data['query'].include?('enablePullRequestAutoMerge') &&
+          data['variables'] == { 'id' => 'PR_kwDOAAABBB4', 'method' => 'SQUASH' }
+      }.
+      to_return(:headers => {'Content-Type' => 'application/json'},
+                :body => '{"data":{"enablePullRequestAutoMerge":{"clientMutationId":null}}}')
+
+    api = Hub::Commands.send(:api_client)
+    api.enable_auto_merge(project, 12, 'squash')
+    api.enable_auto_merge(project, 'PR_kwDOAAABBB4', 'squash')
+    assert_requested mutation, :times => 2
+    assert_requested :get, "https://api.github.com/repos/defunkt/hub/pulls/12", :times => 1
+    assert_raises(ArgumentError) { api.enable_auto_merge(project, 12, 'fast-forward') }
+  end
+
+  def test_api_wait_for_ci_status
+    project = Hub::Context::GithubProject.new(nil, 'defunkt', 'hub', 'github.com')
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/commits/abc123/status").
+      to_return(:body => '{"state":"pending"}', :headers => {'Content-Type' => 'application/json', 'ETag' => '"one"'}).then.
+      to_return(:status => 304).then.
+      to_return(:body => '{"state":"success"}', :headers => {'Content-Type' => 'application/json', 'ETag' => '"two"'})
+
+    api = Hub::Commands.send(:api_client)
+    assert_equal 'success', api.wait_for_ci_status(project, 'abc123', 10, 0)['state']
+    assert_requested :get, "https://api.github.com/repos/defunkt/hub/commits/abc123/status",
+      :headers => {'If-None-Match' => '"one"'}, :times => 2
+
+    stub_request(:get, "https://api.github.com/repos/defunkt/hub/commits/def456/status").
+      to_return(:body => '{"state":"pending"}', :headers => {'Content-Type' => 'application/json'})
+    err = assert_raise(Hub::Context::FatalError) {
+      api.wait_for_ci_status(project, 'def456', 0, 1)
+    }
+    assert_equal "timed out waiting for CI status of def456", err.message
+  end
+
   def test_version
     out = hub('--version')
     assert_includes "git version 1.7.0.4", out
@@ -400,6 +1155,13 @@ class HubTest < Test::Unit::TestCase
     end
   end
 
+  def test_configured_browser
+    with_browser_env(nil) do
+      stub_config_value 'hub.browser', 'firefox --new-tab'
+      assert_command "browse", "firefox --new-tab https://github.com/defunkt/hub"
+    end
+  end
+
   def test_no_browser
     stub_available_commands()
     expected = "Please set $BROWSER to a web launcher to use this command.\n"
@@ -448,6 +1210,10 @@ class HubTest < Test::Unit::TestCase
       stub_config_value "remotes.#{name}", value
     end
 
+    def stub_remotes(*names)
+      stub_command_output 'remote', names.join("\n")
+    end
+
     def stub_no_remotes
       stub_command_output 'remote', nil
     end
@@ -506,6 +1272,13 @@ class HubTest < Test::Unit::TestCase
       ENV['GITHUB_HOST'] = host
     end
 
+    def with_proxy_env(value)
+      proxy, ENV['HTTPS_PROXY'] = ENV['HTTPS_PROXY'], value
+      yield
+    ensure
+      ENV['HTTPS_PROXY'] = proxy
+    end
+
     def assert_browser(browser)
       assert_command "browse", "#{browser} https://github.com/defunkt/hub"
     end
@@ -520,6 +1293,12 @@ class HubTest < Test::Unit::TestCase
       end
     end
 
+    def stub_pullrequest_patch(id)
+      stub_request(:get, "https://api.github.com/repos/defunkt/hub/pulls/#{id}").
+        with(:headers => { 'Accept' => 'application/vnd.github.v3.patch' }).
+        to_return(:body => "From 1234abc\nSubject: [PATCH] Fix\n")
+    end
+
     def mock_pullreq_response(id, name_with_owner = 'defunkt/hub', host = 'github.com')
       Hub::JSON.generate :html_url => "https://#{host}/#{name_with_owner}/pull/#{id}"
     end